@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-client/lib/aiclient"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var askCmd = &cobra.Command{
+	Use:   "ask [text]",
+	Short: "Ask a chat once and print the answer",
+	Long: `Ask a chat once and print the answer. Without text, or with "-", the message is read from stdin.
+
+Exit codes: 0 - answered, 1 - the chat failed, 2 - invalid input.`,
+	Example: `  ask --chat bartender "Negroni recipe"
+  echo "something smoky" | ask --chat waiter -o json`,
+	Run: cmd_ask,
+}
+
+var askOutput string
+var askTimeout time.Duration
+
+func init() {
+	askCmd.Flags().StringVarP(&askOutput, "output", "o", "text", "Output format: text, json (answer with tool trace and token usage)")
+	askCmd.Flags().DurationVar(&askTimeout, "timeout", 2*time.Minute, "Max time for the answer")
+	rootCmd.AddCommand(askCmd)
+}
+
+// askResult is an answer with its trace, the JSON output of ask and a line of batch output.
+type askResult struct {
+	ID      string             `json:"id,omitempty"`
+	Chat    string             `json:"chat"`
+	Session string             `json:"session"`
+	Input   string             `json:"input"`
+	Answer  string             `json:"answer"`
+	Data    json.RawMessage    `json:"data,omitempty"`
+	Trace   aiclient.TurnTrace `json:"trace"`
+	Latency time.Duration      `json:"latency"`
+	Error   string             `json:"error,omitempty"`
+}
+
+func cmd_ask(cmd *cobra.Command, args []string) {
+	if askOutput != "text" && askOutput != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", askOutput)
+		os.Exit(2)
+	}
+
+	input := strings.Join(args, " ")
+	if input == "" || input == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		input = string(data)
+	}
+	input = strings.TrimSpace(input)
+	if input == "" {
+		fmt.Fprintln(os.Stderr, "no message, give it as an argument or on stdin")
+		os.Exit(2)
+	}
+
+	result := runAsk(cmd.Context(), chatName, uuid.NewString(), input, askTimeout)
+
+	if askOutput == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+	} else if result.Error == "" {
+		fmt.Println(result.Answer)
+	}
+	if result.Error != "" {
+		fmt.Fprintln(os.Stderr, "error:", result.Error)
+		os.Exit(1)
+	}
+}
+
+// runAsk answers input in a new session of chat.
+func runAsk(ctx context.Context, chat string, sessionId string, input string, timeout time.Duration) askResult {
+	result := askResult{Chat: chat, Session: sessionId, Input: input}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// an unknown chat or a broken prompt fails only this answer
+	session, err := aiclient.New(cfgFile, sessionId, chat)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer session.Close()
+	start := time.Now()
+	answer, err := session.AskTurn(ctx, input)
+	result.Latency = time.Since(start)
+	result.Trace = answer.Turn
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Answer = answer.Content
+	if answer.Structured {
+		result.Data = json.RawMessage(answer.Content)
+	}
+	return result
+}
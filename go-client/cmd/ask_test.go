@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"go-client/lib/appconfig"
+	"go-client/lib/fakellm"
+	"strings"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestRunAsk(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`(?i)what time`).CallTool("get_current_time", `{}`).Once()
+	llm.OnRole(openai.ChatMessageRoleTool, `.`).Reply("It is late")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"bartender": {Model: "fake", AvailableFunctions: []string{"get_current_time"}},
+		},
+	})
+
+	result := runAsk(context.Background(), "bartender", "ask-session", "What time is it?", time.Minute)
+	if result.Error != "" || result.Answer != "It is late" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(result.Trace.ToolCalls) != 1 || result.Trace.ToolCalls[0].Name != "get_current_time" {
+		t.Errorf("unexpected trace: %+v", result.Trace)
+	}
+	if result.Trace.Usage.TotalTokens == 0 || result.Latency == 0 {
+		t.Errorf("expected usage and latency, got %+v, %s", result.Trace.Usage, result.Latency)
+	}
+
+	if result := runAsk(context.Background(), "nobody", "ask-session", "hi", time.Minute); !strings.Contains(result.Error, "not found") {
+		t.Errorf("expected unknown chat error, got: %+v", result)
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`.`).Reply("ok")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"coordinator": {Model: "fake"},
+			"waiter":      {Model: "fake-waiter"},
+			"broken":      {Model: "fake", Prompt: appconfig.AiChatConfigPrompt{Role: appconfig.PromptSection{File: "no/such/role.md"}}},
+		},
+	})
+
+	input := strings.Join([]string{
+		`{"id": "a", "input": "one"}`,
+		`{"input": "two", "chat": "waiter"}`,
+		`not json`,
+		``,
+		`{"id": "d", "input": ""}`,
+		`{"id": "e", "input": "three", "chat": "nobody"}`,
+		`{"id": "f", "input": "four"}`,
+		`{"id": "g", "input": "five", "chat": "broken"}`,
+	}, "\n")
+
+	var out strings.Builder
+	total, failed, err := runBatch(context.Background(), strings.NewReader(input), &out, 3, time.Minute)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if total != 7 || failed != 4 {
+		t.Errorf("expected 7 results and 4 failures, got %d and %d", total, failed)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	wantIDs := []string{"a", "2", "", "d", "e", "f", "g"}
+	if len(lines) != len(wantIDs) {
+		t.Fatalf("expected %d result lines, got:\n%s", len(wantIDs), out.String())
+	}
+	for i, line := range lines {
+		var result askResult
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("invalid result line %q: %v", line, err)
+		}
+		if result.ID != wantIDs[i] {
+			t.Errorf("line %d: expected id %q, got %q", i+1, wantIDs[i], result.ID)
+		}
+		if ok := result.Error == ""; ok != (i == 0 || i == 1 || i == 5) {
+			t.Errorf("line %d: unexpected result %+v", i+1, result)
+		}
+	}
+	if !strings.Contains(lines[6], "no/such/role.md") || !strings.Contains(lines[2], "line 3: invalid JSON") || !strings.Contains(lines[1], `"chat":"waiter"`) {
+		t.Errorf("unexpected results:\n%s", out.String())
+	}
+
+	models := map[string]int{}
+	for _, r := range llm.Requests() {
+		models[r.Model]++
+	}
+	if models["fake"] != 2 || models["fake-waiter"] != 1 {
+		t.Errorf("expected each prompt in its chat, got requests per model %v", models)
+	}
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch [file]",
+	Short: "Answer JSONL prompts, each in its own session, and write JSONL results",
+	Long: `Answer JSONL prompts, each in its own session, and write JSONL results.
+
+Input lines are {"id": "...", "chat": "...", "input": "..."}, id and chat are optional
+(chat defaults to --chat). Without file, or with "-", prompts are read from stdin.
+Results are written in input order, with the tool trace, token usage and latency.
+Exit code is 1 if any prompt failed.`,
+	Example: `  batch prompts.jsonl --concurrency 4 -o results.jsonl
+  cat prompts.jsonl | batch --chat waiter`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  cmd_batch,
+}
+
+var batchConcurrency int
+var batchOutput string
+var batchTimeout time.Duration
+
+func init() {
+	batchCmd.Flags().IntVarP(&batchConcurrency, "concurrency", "p", 4, "Number of prompts answered at the same time")
+	batchCmd.Flags().StringVarP(&batchOutput, "output", "o", "", "Results file, stdout by default")
+	batchCmd.Flags().DurationVar(&batchTimeout, "timeout", 2*time.Minute, "Max time for an answer")
+	rootCmd.AddCommand(batchCmd)
+}
+
+// batchPrompt is a line of batch input.
+type batchPrompt struct {
+	ID    string `json:"id"`
+	Chat  string `json:"chat"`
+	Input string `json:"input"`
+}
+
+func cmd_batch(cmd *cobra.Command, args []string) {
+	in := io.Reader(os.Stdin)
+	if len(args) > 0 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	out := io.Writer(os.Stdout)
+	if batchOutput != "" {
+		f, err := os.Create(batchOutput)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	total, failed, err := runBatch(cmd.Context(), in, out, batchConcurrency, batchTimeout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("batch done", "prompts", total, "failed", failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// runBatch answers prompts from in at most concurrency at a time and writes
+// results to out in input order. Invalid lines are failed results.
+func runBatch(ctx context.Context, in io.Reader, out io.Writer, concurrency int, timeout time.Duration) (total int, failed int, err error) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	// a result channel per prompt keeps the output in input order
+	pending := make(chan chan askResult, concurrency)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	var readErr error
+	go func() {
+		defer close(pending)
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+		line := 0
+		for scanner.Scan() {
+			line++
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+
+			resultCh := make(chan askResult, 1)
+			pending <- resultCh
+
+			var prompt batchPrompt
+			if err := json.Unmarshal([]byte(text), &prompt); err != nil {
+				resultCh <- askResult{Error: fmt.Sprintf("line %d: invalid JSON: %v", line, err)}
+				continue
+			}
+			if prompt.ID == "" {
+				prompt.ID = fmt.Sprintf("%d", line)
+			}
+			if prompt.Chat == "" {
+				prompt.Chat = chatName
+			}
+			if strings.TrimSpace(prompt.Input) == "" {
+				resultCh <- askResult{ID: prompt.ID, Chat: prompt.Chat, Error: fmt.Sprintf("line %d: input is required", line)}
+				continue
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				resultCh <- askResult{ID: prompt.ID, Chat: prompt.Chat, Input: prompt.Input, Error: ctx.Err().Error()}
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				result := runAsk(ctx, prompt.Chat, uuid.NewString(), prompt.Input, timeout)
+				result.ID = prompt.ID
+				resultCh <- result
+			}()
+		}
+		readErr = scanner.Err()
+	}()
+
+	enc := json.NewEncoder(out)
+	var writeErr error
+	for resultCh := range pending {
+		result := <-resultCh
+		total++
+		if result.Error != "" {
+			failed++
+		}
+		// after a write error prompts are still drained, so the reader ends
+		if writeErr == nil {
+			writeErr = enc.Encode(result)
+		}
+	}
+	wg.Wait()
+	if writeErr != nil {
+		return total, failed, writeErr
+	}
+	return total, failed, readErr
+}
@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
 	"go-client/lib/aiclient"
 	"go-client/lib/appconfig"
 	"go-client/lib/wschat"
 
-	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
@@ -20,11 +23,41 @@ func init() {
 }
 
 func cmd_chat(cmd *cobra.Command, args []string) {
-	sessionId := uuid.NewString()
+	httpPort := appconfig.Current().AiChatCfg[chatName].TmpHttpPort
 
-	httpPort := appconfig.AppCfg.AiChatCfg[chatName].TmpHttpPort
+	watchConfig(cmd.Context())
 
-	ai := aiclient.New(cfgFile, sessionId, chatName)
-	ch := wschat.New(httpPort, ai.Ask)
+	// every connection gets a session of the running config
+	ch := wschat.New(httpPort, func(sessionId string) (wschat.Session, error) {
+		session, err := aiclient.New(cfgFile, sessionId, chatName)
+		if err != nil {
+			return nil, err
+		}
+		return chatSession{session}, nil
+	})
 	ch.Serve()
 }
+
+// chatSession is a session of the chat page,
+// "/reload" switches it to the running config.
+type chatSession struct {
+	session interface {
+		Ask(ctx context.Context, inputMsg string) (string, error)
+		Reload() error
+		Close()
+	}
+}
+
+func (s chatSession) Ask(ctx context.Context, inputMsg string) (string, error) {
+	if strings.TrimSpace(inputMsg) == "/reload" {
+		if err := s.session.Reload(); err != nil {
+			return fmt.Sprintf("Reload failed: %v", err), nil
+		}
+		return "Config reloaded", nil
+	}
+	return s.session.Ask(ctx, inputMsg)
+}
+
+func (s chatSession) Close() {
+	s.session.Close()
+}
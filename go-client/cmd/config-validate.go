@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"go-client/lib/appconfig"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config file (--config) and print all problems found",
+	Run:   cmd_config_validate,
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+}
+
+func cmd_config_validate(cmd *cobra.Command, args []string) {
+	_, err := appconfig.ParseConfig(cfgFile)
+	if err == nil {
+		fmt.Printf("%s is valid\n", cfgFile)
+		return
+	}
+
+	var errs appconfig.ValidationErrors
+	if errors.As(err, &errs) {
+		for _, e := range errs {
+			fmt.Println(e)
+		}
+		fmt.Printf("%d problems found\n", len(errs))
+	} else {
+		fmt.Printf("%s: %v\n", cfgFile, err)
+	}
+	os.Exit(1)
+}
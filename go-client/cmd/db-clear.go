@@ -1,12 +1,10 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
-	"go-client/lib/appconfig"
-	"go-client/lib/cocktail"
-	"go-client/lib/tools"
+	"go-client/lib/aiclient"
 	"log"
+	"log/slog"
 
 	"github.com/spf13/cobra"
 )
@@ -22,14 +20,15 @@ func init() {
 }
 
 func cmd_db_clear(cmd *cobra.Command, args []string) {
-	wvc := tools.GetWeaviateClient(appconfig.AppCfg.Weaviate.Scheme, appconfig.AppCfg.Weaviate.Host)
-
-	ctx := context.Background()
-	cr := cocktail.NewRepository(wvc, &ctx)
+	ctx := cmd.Context()
+	cr, err := aiclient.OpenCocktailStore(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	err := cr.ClearClass()
+	err = cr.ClearClass(ctx)
 	if err != nil {
-		log.Println(err)
+		slog.Error("clearing class failed", "err", err)
 	} else {
 		fmt.Println("Class Cocktail deleted")
 	}
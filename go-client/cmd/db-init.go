@@ -1,11 +1,10 @@
 package cmd
 
 import (
-	"context"
-	"go-client/lib/appconfig"
+	"go-client/lib/aiclient"
 	"go-client/lib/cocktail"
-	"go-client/lib/tools"
 	"log"
+	"log/slog"
 
 	"github.com/spf13/cobra"
 )
@@ -21,14 +20,16 @@ func init() {
 }
 
 func cmd_db_init(cmd *cobra.Command, args []string) {
-	wvc := tools.GetWeaviateClient(appconfig.AppCfg.Weaviate.Scheme, appconfig.AppCfg.Weaviate.Host)
-	ctx := context.Background()
-	cr := cocktail.NewRepository(wvc, &ctx)
+	ctx := cmd.Context()
+	cr, err := aiclient.OpenCocktailStore(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	err := cr.InitClass()
+	err = cr.InitClass(ctx)
 	if err != nil {
-		log.Println(err)
+		slog.Error("creating class failed", "err", err)
 	} else {
-		log.Printf("Class %s created", cocktail.CocktailClassName)
+		slog.Info("class created", "class", cocktail.CocktailClassName)
 	}
 }
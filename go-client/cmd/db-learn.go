@@ -1,14 +1,12 @@
 package cmd
 
 import (
-	"context"
+	"go-client/lib/aiclient"
 	"go-client/lib/appconfig"
 	"go-client/lib/cocktail"
-	"go-client/lib/tools"
 	"log"
-	"os"
+	"log/slog"
 
-	"github.com/gocarina/gocsv"
 	"github.com/spf13/cobra"
 )
 
@@ -35,28 +33,28 @@ func init() {
 }
 
 func cmd_db_learn(cmd *cobra.Command, args []string) {
-	var cocktails []cocktail.Cocktail
-
 	path := "data/cocktails.csv"
 
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, os.ModePerm)
+	// the memory store would be thrown away on exit
+	if store := appconfig.Current().Store; store != nil && store.Type == aiclient.StoreMemory {
+		log.Fatal("db learn needs Weaviate, the memory store is loaded from store.data at start")
+	}
+
+	cocktails, err := cocktail.LoadCSV(path)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer f.Close()
 
-	if err := gocsv.UnmarshalFile(f, &cocktails); err != nil {
+	ctx := cmd.Context()
+	cr, err := aiclient.OpenCocktailStore(ctx)
+	if err != nil {
 		log.Fatal(err)
 	}
 
-	wvc := tools.GetWeaviateClient(appconfig.AppCfg.Weaviate.Scheme, appconfig.AppCfg.Weaviate.Host)
-	ctx := context.Background()
-	cr := cocktail.NewRepository(wvc, &ctx)
-
 	for _, c := range cocktails {
-		if err := cr.Save(c); err != nil {
+		if err := cr.Save(ctx, c); err != nil {
 			log.Fatal(err)
 		}
-		log.Printf("Added: %s", c.Name)
+		slog.Info("cocktail added", "name", c.Name)
 	}
 }
@@ -1,40 +1,119 @@
 package cmd
 
 import (
-	"context"
+	"encoding/json"
 	"fmt"
-	"go-client/lib/appconfig"
-	"go-client/lib/appdebug"
+	"go-client/lib/aiclient"
 	"go-client/lib/cocktail"
-	"go-client/lib/tools"
+	"io"
 	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 )
 
 var dbQueryCmd = &cobra.Command{
-	Use:   "query",
+	Use:   "query [text]",
 	Short: "Query Coctail class",
-	Run:   cmd_db_query,
+	Example: `  db query "sweet exotic" --limit 5 --distance 0.7
+  db query chartreuse --mode bm25 -o json
+  db query mezcal --mode hybrid --alpha 0.5 --filter "ingredients!=gin" -o jsonl
+  db query Negroni --mode name`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  cmd_db_query,
 }
 
+var dbQueryLimit int
+var dbQueryDistance float32
+var dbQueryAlpha float32
+var dbQueryMode string
+var dbQueryFilters []string
+var dbQueryOutput string
+
 func init() {
+	dbQueryCmd.Flags().IntVarP(&dbQueryLimit, "limit", "l", 3, "Max number of results")
+	dbQueryCmd.Flags().Float32VarP(&dbQueryDistance, "distance", "d", 0, "Max vector distance, 0 - no threshold (near-text only)")
+	dbQueryCmd.Flags().Float32VarP(&dbQueryAlpha, "alpha", "a", 0.75, "Hybrid search weighting: 0 - pure BM25, 1 - pure vector (hybrid only)")
+	dbQueryCmd.Flags().StringVarP(&dbQueryMode, "mode", "m", string(cocktail.SearchNearText), "Search mode: near-text, bm25, hybrid, name")
+	dbQueryCmd.Flags().StringArrayVarP(&dbQueryFilters, "filter", "f", nil, "Property filter: property=value, property!=value or property~value (repeatable)")
+	dbQueryCmd.Flags().StringVarP(&dbQueryOutput, "output", "o", "table", "Output format: table, json, jsonl")
 	dbCmd.AddCommand(dbQueryCmd)
 }
 
 func cmd_db_query(cmd *cobra.Command, args []string) {
-	wvc := tools.GetWeaviateClient(appconfig.AppCfg.Weaviate.Scheme, appconfig.AppCfg.Weaviate.Host)
-	ctx := context.Background()
-	cr := cocktail.NewRepository(wvc, &ctx)
+	mode, err := cocktail.ParseSearchMode(dbQueryMode)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	searchText := "Sweet exotic"
-	fmt.Println("Search text ", searchText)
+	query := cocktail.SearchQuery{
+		Text:     strings.Join(args, " "),
+		Mode:     mode,
+		Limit:    dbQueryLimit,
+		Distance: dbQueryDistance,
+	}
+	if cmd.Flags().Changed("alpha") {
+		query.Alpha = &dbQueryAlpha
+	}
+	for _, f := range dbQueryFilters {
+		filter, err := cocktail.ParseFilter(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		query.Filters = append(query.Filters, filter)
+	}
 
-	result, err := cr.GetListByNearText(searchText, 3)
+	ctx := cmd.Context()
+	cr, err := aiclient.OpenCocktailStore(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	appdebug.PrettyPrint(result)
+	slog.Info("searching", "text", query.Text, "mode", query.Mode, "limit", query.Limit)
 
+	results, err := cr.Search(ctx, query)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := printSearchResults(os.Stdout, dbQueryOutput, results); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printSearchResults(w io.Writer, format string, results []cocktail.SearchResult) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		for _, r := range results {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "#\tNAME\tDISTANCE\tCERTAINTY\tSCORE\tINGREDIENTS")
+		for i, r := range results {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n",
+				i+1, r.Name, formatScore(r.Distance), formatScore(r.Certainty), formatScore(r.Score), r.Ingredients)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+func formatScore(v *float32) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.4f", *v)
 }
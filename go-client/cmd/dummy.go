@@ -3,7 +3,7 @@ package cmd
 import (
 	"fmt"
 	"go-client/lib/aiclient"
-	"log"
+	"log/slog"
 
 	"github.com/spf13/cobra"
 )
@@ -19,16 +19,15 @@ func init() {
 }
 
 func cmd_dummy(cmd *cobra.Command, args []string) {
-	log.Println("Dummy command here")
+	slog.Info("dummy command here")
 
-	// appdebug.PrettyPrint(appconfig.AppCfg)
+	// appdebug.PrettyPrint(appconfig.Current())
 
-	// wvc := tools.GetWeaviateClient(appconfig.AppCfg.Weaviate.Scheme, appconfig.AppCfg.Weaviate.Host)
-	// ctx := context.Background()
-	// cr := cocktail.NewRepository(wvc, &ctx)
+	// ctx := cmd.Context()
+	// cr, err := aiclient.OpenCocktailStore(ctx)
 
-	// // cocktails, err := cr.GetListByNearText("Varmouth", 3)
-	// cocktails, err := cr.GetByCocktailName("Cove")
+	// // cocktails, err := cr.GetListByNearText(ctx, "Varmouth", 3)
+	// cocktails, err := cr.GetByCocktailName(ctx, "Cove")
 	// if err != nil {
 	// 	log.Fatal(err)
 	// }
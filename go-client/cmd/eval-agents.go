@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-client/lib/aiclient"
+	"go-client/lib/evaluation"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var evalAgentsCmd = &cobra.Command{
+	Use:   "agents",
+	Short: "Run scripted conversations against chats and check assertions",
+	Run:   cmd_eval_agents,
+}
+
+var evalAgentsScripts string
+var evalAgentsParallel int
+var evalAgentsReport string
+var evalAgentsJUnit string
+
+func init() {
+	evalAgentsCmd.Flags().StringVarP(&evalAgentsScripts, "scripts", "s", "data/eval/agents", "Script file or directory with *.yaml scripts")
+	evalAgentsCmd.Flags().IntVarP(&evalAgentsParallel, "parallel", "p", 2, "Number of scripts run in parallel")
+	evalAgentsCmd.Flags().StringVarP(&evalAgentsReport, "report", "r", "agents-report.json", "JSON report file")
+	evalAgentsCmd.Flags().StringVar(&evalAgentsJUnit, "junit", "agents-report.xml", "JUnit XML report file")
+	evalCmd.AddCommand(evalAgentsCmd)
+}
+
+func cmd_eval_agents(cmd *cobra.Command, args []string) {
+	scripts, err := evaluation.LoadScripts(evalAgentsScripts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	newAgent := func(chat string) (evaluation.Agent, error) {
+		if chat == "" {
+			chat = chatName
+		}
+		agent, err := aiclient.New(cfgFile, uuid.NewString(), chat)
+		if err != nil {
+			return nil, err
+		}
+		return agent, nil
+	}
+
+	report := evaluation.RunScripts(cmd.Context(), newAgent, scripts, evalAgentsParallel)
+
+	for _, s := range report.Scripts {
+		status := "PASS"
+		if !s.Passed {
+			status = "FAIL"
+		}
+		fmt.Printf("%s  %s (%s)\n", status, s.Name, s.Duration.Round(time.Millisecond))
+		if s.Error != "" {
+			fmt.Printf("      error: %s\n", s.Error)
+		}
+		for i, t := range s.Turns {
+			for _, f := range t.Failures {
+				fmt.Printf("      turn %d: %s\n", i+1, f)
+			}
+		}
+	}
+	fmt.Printf("%d passed, %d failed\n", report.Passed, report.Failed)
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(evalAgentsReport, append(data, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	f, err := os.Create(evalAgentsJUnit)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if err := report.WriteJUnit(f); err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("reports written", "report", evalAgentsReport, "junit", evalAgentsJUnit)
+
+	if report.Failed > 0 {
+		f.Close()
+		os.Exit(1)
+	}
+}
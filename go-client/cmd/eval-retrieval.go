@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-client/lib/aiclient"
+	"go-client/lib/cocktail"
+	"go-client/lib/evaluation"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var evalRetrievalCmd = &cobra.Command{
+	Use:   "retrieval",
+	Short: "Evaluate cocktail retrieval with recall@k, precision@k and MRR",
+	Run:   cmd_eval_retrieval,
+}
+
+var evalRetrievalDataset string
+var evalRetrievalModes []string
+var evalRetrievalDistance float32
+var evalRetrievalAlpha float32
+var evalRetrievalReport string
+
+func init() {
+	evalRetrievalCmd.Flags().StringVarP(&evalRetrievalDataset, "dataset", "d", "data/eval/retrieval.yaml", "Dataset file")
+	evalRetrievalCmd.Flags().StringSliceVarP(&evalRetrievalModes, "modes", "m", []string{"near-text", "bm25", "hybrid"}, "Search modes to evaluate")
+	evalRetrievalCmd.Flags().Float32Var(&evalRetrievalDistance, "distance", 0, "Max vector distance, 0 - no threshold (near-text only)")
+	evalRetrievalCmd.Flags().Float32VarP(&evalRetrievalAlpha, "alpha", "a", 0.75, "Hybrid search weighting: 0 - pure BM25, 1 - pure vector (hybrid only)")
+	evalRetrievalCmd.Flags().StringVarP(&evalRetrievalReport, "report", "r", "retrieval-report.json", "JSON report file")
+	evalCmd.AddCommand(evalRetrievalCmd)
+}
+
+func cmd_eval_retrieval(cmd *cobra.Command, args []string) {
+	ds, err := evaluation.LoadRetrievalDataset(evalRetrievalDataset)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	modes := []cocktail.SearchMode{}
+	for _, m := range evalRetrievalModes {
+		mode, err := cocktail.ParseSearchMode(strings.TrimSpace(m))
+		if err != nil {
+			log.Fatal(err)
+		}
+		modes = append(modes, mode)
+	}
+
+	base := cocktail.SearchQuery{Distance: evalRetrievalDistance}
+	if cmd.Flags().Changed("alpha") {
+		base.Alpha = &evalRetrievalAlpha
+	}
+
+	ctx := cmd.Context()
+	cr, err := aiclient.OpenCocktailStore(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := evaluation.RunRetrieval(ctx, cr.Search, ds, modes, base)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "MODE\tRECALL@%d\tPRECISION@%d\tMRR\n", report.K, report.K)
+	for _, m := range report.Modes {
+		fmt.Fprintf(tw, "%s\t%.3f\t%.3f\t%.3f\n", m.Mode, m.Recall, m.Precision, m.MRR)
+	}
+	fmt.Fprintf(tw, "total\t%.3f\t%.3f\t%.3f\n", report.Total.Recall, report.Total.Precision, report.Total.MRR)
+	tw.Flush()
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(evalRetrievalReport, append(data, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("report written", "report", evalRetrievalReport)
+}
@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var evalCmd = &cobra.Command{
+	Use:   "eval",
+	Short: "Evaluation tools",
+}
+
+func init() {
+	rootCmd.AddCommand(evalCmd)
+}
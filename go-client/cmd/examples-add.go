@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"go-client/lib/aiclient"
+	"go-client/lib/examples"
+	"log"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+)
+
+var examplesAddCmd = &cobra.Command{
+	Use:     "add",
+	Short:   "Add an example to a chat",
+	Example: `  examples add --chat waiter --input "something with mezcal" --output "- Mezcal Negroni\n- Oaxaca Old Fashioned"`,
+	Run:     cmd_examples_add,
+}
+
+var examplesAddInput string
+var examplesAddOutput string
+
+func init() {
+	examplesAddCmd.Flags().StringVarP(&examplesAddInput, "input", "i", "", "User message")
+	examplesAddCmd.Flags().StringVarP(&examplesAddOutput, "output", "o", "", "Expected answer")
+	examplesAddCmd.MarkFlagRequired("input")
+	examplesAddCmd.MarkFlagRequired("output")
+	examplesCmd.AddCommand(examplesAddCmd)
+}
+
+func cmd_examples_add(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+	store, err := aiclient.OpenExampleStore(ctx, chatName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := store.Add(ctx, examples.Example{Input: examplesAddInput, Output: examplesAddOutput}); err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("example added", "chat", chatName)
+}
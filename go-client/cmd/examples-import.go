@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"go-client/lib/aiclient"
+	"go-client/lib/examples"
+	"log"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+)
+
+var examplesImportCmd = &cobra.Command{
+	Use:     "import [file]",
+	Short:   "Import examples to a chat from a YAML list or a JSONL file",
+	Example: `  examples import --chat waiter data/examples/waiter.yaml`,
+	Args:    cobra.ExactArgs(1),
+	Run:     cmd_examples_import,
+}
+
+func init() {
+	examplesCmd.AddCommand(examplesImportCmd)
+}
+
+func cmd_examples_import(cmd *cobra.Command, args []string) {
+	items, err := examples.LoadFile(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := cmd.Context()
+	store, err := aiclient.OpenExampleStore(ctx, chatName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, ex := range items {
+		if err := store.Add(ctx, ex); err != nil {
+			log.Fatal(err)
+		}
+	}
+	slog.Info("examples imported", "count", len(items), "chat", chatName)
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-client/lib/aiclient"
+	"go-client/lib/examples"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var examplesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List examples of a chat",
+	Example: `  examples list --chat waiter
+  examples list --chat waiter --similar "smoky drink" -k 3 -o json`,
+	Run: cmd_examples_list,
+}
+
+var examplesListSimilar string
+var examplesListK int
+var examplesListOutput string
+
+func init() {
+	examplesListCmd.Flags().StringVarP(&examplesListSimilar, "similar", "s", "", "List only examples most similar to this text, as selected for a user message")
+	examplesListCmd.Flags().IntVarP(&examplesListK, "k", "k", 3, "Number of similar examples (with --similar)")
+	examplesListCmd.Flags().StringVarP(&examplesListOutput, "output", "o", "table", "Output format: table, json")
+	examplesCmd.AddCommand(examplesListCmd)
+}
+
+func cmd_examples_list(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+	store, err := aiclient.OpenExampleStore(ctx, chatName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var items []examples.ScoredExample
+	if examplesListSimilar != "" {
+		items, err = store.Similar(ctx, examplesListSimilar, examplesListK, 0)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		list, err := store.List(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, ex := range list {
+			items = append(items, examples.ScoredExample{Example: ex})
+		}
+	}
+
+	if err := printExamples(os.Stdout, examplesListOutput, items, examplesListSimilar != ""); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printExamples(w io.Writer, format string, items []examples.ScoredExample, withDistance bool) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if withDistance {
+			return enc.Encode(items)
+		}
+		list := make([]examples.Example, len(items))
+		for i, ex := range items {
+			list[i] = ex.Example
+		}
+		return enc.Encode(list)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		if withDistance {
+			fmt.Fprintln(tw, "#\tDISTANCE\tINPUT\tOUTPUT")
+		} else {
+			fmt.Fprintln(tw, "#\tINPUT\tOUTPUT")
+		}
+		for i, ex := range items {
+			output := strings.ReplaceAll(ex.Output, "\n", " ")
+			if withDistance {
+				fmt.Fprintf(tw, "%d\t%.4f\t%s\t%s\n", i+1, ex.Distance, ex.Input, output)
+			} else {
+				fmt.Fprintf(tw, "%d\t%s\t%s\n", i+1, ex.Input, output)
+			}
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
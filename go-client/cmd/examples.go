@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var examplesCmd = &cobra.Command{
+	Use:   "examples",
+	Short: "Few-shot examples of a chat (--chat)",
+}
+
+func init() {
+	rootCmd.AddCommand(examplesCmd)
+}
@@ -3,13 +3,19 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go-client/lib/aiclient"
 	"go-client/lib/appconfig"
+	"go-client/lib/applog"
 	"go-client/lib/httptools"
+	"go-client/lib/metrics"
+	"go-client/lib/usage"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -30,17 +36,213 @@ func init() {
 }
 
 func cmd_http(cmd *cobra.Command, args []string) {
-	sessionId := uuid.NewString()
+	watchConfig(cmd.Context())
 
-	ai := aiclient.New(cfgFile, sessionId, chatName)
+	// every caller session gets a session of the running config
+	r := newHttpRouter(cmd.Context(), func(sessionId string) (httpSession, error) {
+		session, err := aiclient.New(cfgFile, sessionId, chatName)
+		if err != nil {
+			return nil, err
+		}
+		return session, nil
+	})
+
+	httpPort := appconfig.Current().AiChatCfg[chatName].TmpHttpPort
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", httpPort),
+		Handler:      r,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  120 * time.Second,
+	}
+
+	// Start server and handle graceful shutdown
+	serverErrCh := make(chan error, 1)
+	go func() {
+		serverErrCh <- srv.ListenAndServe()
+	}()
+
+	shutdownCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serverErrCh:
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("server error", "err", err)
+		}
+	case <-shutdownCtx.Done():
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			slog.Error("graceful shutdown error", "err", err)
+		}
+	}
+
+}
+
+// httpSession is the chat session served over HTTP.
+type httpSession interface {
+	AskTurn(ctx context.Context, inputMsg string) (aiclient.Answer, error)
+	Reload() error
+	Usage() usage.Usage
+	Close()
+}
+
+const (
+	// sessionIdleTimeout closes HTTP sessions without requests for that long
+	sessionIdleTimeout = 30 * time.Minute
+	// sessionSweepInterval is how often idle sessions are looked for
+	sessionSweepInterval = time.Minute
+	// maxHttpSessions limits sessions kept at a time, callers choose session IDs
+	maxHttpSessions = 1000
+)
+
+// errTooManySessions is returned for a new session when all kept sessions are in use.
+var errTooManySessions = errors.New("too many sessions, try again later")
+
+// httpSessions keeps a session for every caller, told by the session ID header.
+// Callers without the header get a new session, its ID is returned in the header.
+// With max sessions kept, the least recently used idle one makes room for a new one.
+type httpSessions struct {
+	newSession func(sessionId string) (httpSession, error)
+	max        int
+
+	mu       sync.Mutex
+	sessions map[string]*pooledSession
+}
+
+type pooledSession struct {
+	httpSession
+	lastUsed time.Time
+	inUse    int
+}
+
+// newHttpSessions keeps at most max sessions, idle ones are closed until ctx is done.
+func newHttpSessions(ctx context.Context, newSession func(sessionId string) (httpSession, error), max int) *httpSessions {
+	p := &httpSessions{newSession: newSession, max: max, sessions: map[string]*pooledSession{}}
+	go p.sweep(ctx)
+	return p
+}
+
+func (p *httpSessions) sweep(ctx context.Context) {
+	ticker := time.NewTicker(sessionSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.closeIdle(now)
+		}
+	}
+}
+
+// closeIdle closes sessions not used for sessionIdleTimeout at now.
+func (p *httpSessions) closeIdle(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, s := range p.sessions {
+		if s.inUse == 0 && now.Sub(s.lastUsed) > sessionIdleTimeout {
+			s.Close()
+			delete(p.sessions, id)
+		}
+	}
+}
+
+// get returns the session of sessionId, made when missing and create is set.
+// The session is not closed as idle until release is called. Sessions are
+// made without holding the lock, a slow one doesn't hold up other callers.
+func (p *httpSessions) get(sessionId string, create bool) (session httpSession, release func(), err error) {
+	p.mu.Lock()
+	s, ok := p.sessions[sessionId]
+	if !ok && create && !p.hasRoom() {
+		p.mu.Unlock()
+		return nil, func() {}, errTooManySessions
+	}
+	if ok {
+		s.inUse++
+		s.lastUsed = time.Now()
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		if !create {
+			return nil, func() {}, nil
+		}
+		if s, err = p.add(sessionId); err != nil {
+			return nil, func() {}, err
+		}
+	}
+	return s.httpSession, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		s.inUse--
+		s.lastUsed = time.Now()
+	}, nil
+}
 
-	_ = ai
+// add makes the session of sessionId, unless another request of the caller did it meanwhile.
+func (p *httpSessions) add(sessionId string) (*pooledSession, error) {
+	session, err := p.newSession(sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.sessions[sessionId]
+	if ok {
+		session.Close()
+	} else {
+		if !p.hasRoom() {
+			session.Close()
+			return nil, errTooManySessions
+		}
+		s = &pooledSession{httpSession: session}
+		p.sessions[sessionId] = s
+	}
+	s.inUse++
+	s.lastUsed = time.Now()
+	return s, nil
+}
+
+// hasRoom tells if a session can be added, closing the least recently used
+// idle session when the pool is full. p.mu must be held.
+func (p *httpSessions) hasRoom() bool {
+	if len(p.sessions) < p.max {
+		return true
+	}
+	var lruId string
+	var lru *pooledSession
+	for id, s := range p.sessions {
+		if s.inUse == 0 && (lru == nil || s.lastUsed.Before(lru.lastUsed)) {
+			lruId, lru = id, s
+		}
+	}
+	if lru == nil {
+		return false
+	}
+	lru.Close()
+	delete(p.sessions, lruId)
+	return true
+}
+
+// newHttpRouter serves chat API, /api/ask answers a user message in the
+// session of the caller, made by newSession. Structured answers are validated
+// JSON, returned also as data, with usage of the turn. /api/reload switches
+// the session to the running config. /api/usage returns usage of the session
+// and of every chat of the process, /metrics all metrics for Prometheus.
+// Idle sessions are closed until ctx is done.
+func newHttpRouter(ctx context.Context, newSession func(sessionId string) (httpSession, error)) http.Handler {
+	sessions := newHttpSessions(ctx, newSession, maxHttpSessions)
 
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
+	r.Use(logRequests)
+	r.Use(metrics.Middleware)
 	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("pong"))
 	})
+	r.Method(http.MethodGet, "/metrics", metrics.Handler())
 	r.Route("/api", func(r chi.Router) {
 		r.Post("/ask", func(w http.ResponseWriter, r *http.Request) {
 			// Limit request body size
@@ -67,64 +269,105 @@ func cmd_http(cmd *cobra.Command, args []string) {
 			promptBuilder := aiclient.PromptBuilder()
 			prompt := promptBuilder.WithTask(req.Content).Get()
 
-			// Run AI call with timeout and return structured errors
+			// Run AI call with timeout and return structured errors.
+			// The context is passed down, so a timeout or a client
+			// disconnect stops the model, tool and Weaviate calls.
 			ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
 			defer cancel()
-			type aiResult struct {
-				resp string
-				err error
+			ctx = aiclient.WithRequestPrompt(ctx, aiclient.RequestPrompt{User: req.User, Retrieved: req.Retrieved})
+
+			sessionId := r.Header.Get(httptools.SessionIDHeader)
+			if sessionId == "" {
+				sessionId = uuid.NewString()
+			}
+			w.Header().Set(httptools.SessionIDHeader, sessionId)
+			session, release, err := sessions.get(sessionId, true)
+			defer release()
+			if errors.Is(err, errTooManySessions) {
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				return
 			}
-			resultCh := make(chan aiResult, 1)
-			go func() {
-				resp, err := ai.Ask(prompt)
-				resultCh <- aiResult{resp: resp, err: err}
-			}()
-
-			select {
-			case <-ctx.Done():
+
+			answer, err := session.AskTurn(ctx, prompt)
+			if errors.Is(err, aiclient.ErrBudgetExceeded) {
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
 				w.WriteHeader(http.StatusGatewayTimeout)
 				json.NewEncoder(w).Encode(map[string]string{"error": "AI request timed out"})
 				return
-			case res := <-resultCh:
-				if res.err != nil {
-					w.WriteHeader(http.StatusInternalServerError)
-					json.NewEncoder(w).Encode(map[string]string{"error": res.err.Error()})
-					return
-				}
-				json.NewEncoder(w).Encode(httptools.ResponseData{Content: res.resp})
 			}
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			respData := httptools.ResponseData{Content: answer.Content, Usage: &answer.Turn.Usage}
+			if answer.Structured {
+				respData.Data = json.RawMessage(answer.Content)
+			}
+			json.NewEncoder(w).Encode(respData)
+		})
+		r.Post("/reload", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			session, release, _ := sessions.get(r.Header.Get(httptools.SessionIDHeader), false)
+			defer release()
+			if session == nil {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]string{"error": "session not found"})
+				return
+			}
+			if err := session.Reload(); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"status": "reloaded"})
+		})
+		r.Get("/usage", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			report := map[string]interface{}{"chats": usage.ByChat()}
+			if session, release, _ := sessions.get(r.Header.Get(httptools.SessionIDHeader), false); session != nil {
+				report["session"] = session.Usage()
+				release()
+			}
+			json.NewEncoder(w).Encode(report)
 		})
 	})
 
-	httpPort := appconfig.AppCfg.AiChatCfg[chatName].TmpHttpPort
-	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", httpPort),
-		Handler: r,
-		ReadTimeout: 10 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout: 120 * time.Second,
-	}
-
-	// Start server and handle graceful shutdown
-	serverErrCh := make(chan error, 1)
-	go func() {
-		serverErrCh <- srv.ListenAndServe()
-	}()
-
-	shutdownCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
+	return r
+}
 
-	select {
-	case err := <-serverErrCh:
-		if err != nil && err != http.ErrServerClosed {
-			fmt.Println("server error:", err)
-		}
-	case <-shutdownCtx.Done():
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		if err := srv.Shutdown(ctx); err != nil {
-			fmt.Println("graceful shutdown error:", err)
+// logRequests logs requests with their trace ID, taken from the trace header
+// of the caller or new. The ID is returned in the header and logged on every
+// line of the request.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID := r.Header.Get(httptools.TraceIDHeader)
+		if traceID == "" {
+			traceID = uuid.NewString()
 		}
-	}
+		w.Header().Set(httptools.TraceIDHeader, traceID)
+		ctx := applog.WithTraceID(r.Context(), traceID)
+
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
 
+		applog.Component("http").InfoContext(ctx, "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", ww.Status(),
+			"bytes", ww.BytesWritten(),
+			"duration", time.Since(start),
+		)
+	})
 }
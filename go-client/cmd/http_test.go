@@ -0,0 +1,367 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"go-client/lib/aiclient"
+	"go-client/lib/appconfig"
+	"go-client/lib/applog"
+	"go-client/lib/fakellm"
+	"go-client/lib/httptools"
+	"go-client/lib/usage"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// askFunc is a session answering with a function.
+type askFunc func(ctx context.Context, inputMsg string) (string, error)
+
+func (f askFunc) AskTurn(ctx context.Context, inputMsg string) (aiclient.Answer, error) {
+	content, err := f(ctx, inputMsg)
+	return aiclient.Answer{Content: content}, err
+}
+
+func (f askFunc) Reload() error { return nil }
+
+func (f askFunc) Usage() usage.Usage { return usage.Usage{} }
+
+func (f askFunc) Close() {}
+
+// session makes every session answer with f.
+func (f askFunc) session(sessionId string) (httpSession, error) { return f, nil }
+
+type askResponse struct {
+	httptools.ResponseData
+	Error string `json:"error"`
+}
+
+func postAsk(t *testing.T, url string, content string) (int, askResponse) {
+	t.Helper()
+	return postAskAs(t, url, "", content)
+}
+
+// postAskAs asks in the session of sessionId.
+func postAskAs(t *testing.T, url string, sessionId string, content string) (int, askResponse) {
+	t.Helper()
+	body, _ := json.Marshal(httptools.RequestData{Content: content})
+	req, _ := http.NewRequest(http.MethodPost, url+"/api/ask", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(httptools.SessionIDHeader, sessionId)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var data askResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		t.Fatalf("invalid response JSON: %v", err)
+	}
+	return resp.StatusCode, data
+}
+
+func TestHttpRouter_Ask(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		ask        func(ctx context.Context, inputMsg string) (string, error)
+		wantStatus int
+	}{
+		{
+			name:       "answer",
+			content:    "hello",
+			ask:        func(ctx context.Context, inputMsg string) (string, error) { return "hi", nil },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "empty content",
+			content:    "",
+			ask:        func(ctx context.Context, inputMsg string) (string, error) { return "hi", nil },
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "timeout",
+			content:    "hello",
+			ask:        func(ctx context.Context, inputMsg string) (string, error) { return "", context.DeadlineExceeded },
+			wantStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name:       "over budget",
+			content:    "hello",
+			ask:        func(ctx context.Context, inputMsg string) (string, error) { return "", aiclient.ErrBudgetExceeded },
+			wantStatus: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(newHttpRouter(t.Context(), askFunc(tt.ask).session))
+			defer srv.Close()
+
+			status, _ := postAsk(t, srv.URL, tt.content)
+			if status != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, status)
+			}
+		})
+	}
+}
+
+func TestHttpRouter_Sessions(t *testing.T) {
+	var created []string
+	srv := httptest.NewServer(newHttpRouter(t.Context(), func(sessionId string) (httpSession, error) {
+		created = append(created, sessionId)
+		// a session answers with the model of the config it was made with
+		model := appconfig.Current().AiChatCfg["talker"].Model
+		return askFunc(func(ctx context.Context, inputMsg string) (string, error) { return model, nil }), nil
+	}))
+	defer srv.Close()
+
+	ask := func(sessionId string) (string, string) {
+		body, _ := json.Marshal(httptools.RequestData{Content: "hello"})
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/ask", bytes.NewReader(body))
+		req.Header.Set(httptools.SessionIDHeader, sessionId)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var data askResponse
+		json.NewDecoder(resp.Body).Decode(&data)
+		return resp.Header.Get(httptools.SessionIDHeader), data.Content
+	}
+
+	appconfig.Set(&appconfig.AppConfig{AiChatCfg: map[string]*appconfig.AiChatConfig{"talker": {Model: "old"}}})
+	if id, answer := ask("a"); id != "a" || answer != "old" {
+		t.Errorf("unexpected answer %q in session %q", answer, id)
+	}
+	appconfig.Set(&appconfig.AppConfig{AiChatCfg: map[string]*appconfig.AiChatConfig{"talker": {Model: "new"}}})
+	if _, answer := ask("a"); answer != "old" {
+		t.Errorf("expected session a to keep its config, got %q", answer)
+	}
+	if _, answer := ask("b"); answer != "new" {
+		t.Errorf("expected a new session to get the new config, got %q", answer)
+	}
+	if id, _ := ask(""); id == "" {
+		t.Error("expected a new session ID for a caller without one")
+	}
+	if len(created) != 3 {
+		t.Errorf("expected 3 sessions, got %q", created)
+	}
+
+	resp, err := http.Post(srv.URL+"/api/reload", "application/json", nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 reloading an unknown session, got %d", resp.StatusCode)
+	}
+}
+
+func TestHttpRouter_SessionStartFails(t *testing.T) {
+	srv := httptest.NewServer(newHttpRouter(t.Context(), func(sessionId string) (httpSession, error) {
+		return nil, errors.New("prompt file missing")
+	}))
+	defer srv.Close()
+
+	if status, data := postAsk(t, srv.URL, "hello"); status != http.StatusInternalServerError || data.Error != "prompt file missing" {
+		t.Errorf("expected 500 with the session error, got %d: %+v", status, data)
+	}
+}
+
+// closingSession records that it was closed.
+type closingSession struct {
+	askFunc
+	closed *bool
+}
+
+func (s closingSession) Close() { *s.closed = true }
+
+func TestHttpSessions_Limit(t *testing.T) {
+	closed := map[string]*bool{}
+	sessions := newHttpSessions(t.Context(), func(sessionId string) (httpSession, error) {
+		closed[sessionId] = new(bool)
+		return closingSession{closed: closed[sessionId]}, nil
+	}, 2)
+
+	_, releaseA, _ := sessions.get("a", true)
+	_, releaseB, _ := sessions.get("b", true)
+	if _, _, err := sessions.get("c", true); !errors.Is(err, errTooManySessions) {
+		t.Fatalf("expected too many sessions with all in use, got %v", err)
+	}
+	if _, ok := closed["c"]; ok {
+		t.Error("expected no session made for a full pool")
+	}
+
+	// the least recently used idle session makes room
+	releaseB()
+	releaseA()
+	_, releaseC, err := sessions.get("c", true)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	releaseC()
+	if !*closed["b"] || *closed["a"] {
+		t.Errorf("expected session b closed, a kept, got a=%v b=%v", *closed["a"], *closed["b"])
+	}
+
+	sessions.closeIdle(time.Now().Add(sessionIdleTimeout + time.Second))
+	if !*closed["a"] || !*closed["c"] {
+		t.Error("expected idle sessions closed")
+	}
+	if session, _, _ := sessions.get("a", false); session != nil {
+		t.Error("expected closed session removed")
+	}
+}
+
+func TestHttpRouter_TraceID(t *testing.T) {
+	var traceIDs []string
+	srv := httptest.NewServer(newHttpRouter(t.Context(), askFunc(func(ctx context.Context, inputMsg string) (string, error) {
+		traceIDs = append(traceIDs, applog.TraceID(ctx))
+		return "hi", nil
+	}).session))
+	defer srv.Close()
+
+	for _, traceID := range []string{"trace-1", ""} {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/ask", strings.NewReader(`{"content": "hello"}`))
+		req.Header.Set(httptools.TraceIDHeader, traceID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get(httptools.TraceIDHeader); got == "" || (traceID != "" && got != traceID) {
+			t.Errorf("unexpected trace ID header %q for %q", got, traceID)
+		}
+	}
+	if len(traceIDs) != 2 || traceIDs[0] != "trace-1" || traceIDs[1] == "" {
+		t.Errorf("unexpected trace IDs in context: %q", traceIDs)
+	}
+}
+
+// TestHttpRouter_MultiAgent runs a coordinator calling a waiter over HTTP, both on a fake model.
+// The waiter answers with structured output, repaired after the first invalid answer.
+func TestHttpRouter_MultiAgent(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`I'd like something smoky`).CallTool("waiter", `{"request": "a cocktail with mezcal"}`)
+	llm.On(`doesn't match the required JSON schema`).Reply("```json\n{\"suggestions\": [\"Mezcal Margarita\"]}\n```")
+	llm.On(`a cocktail with mezcal`).Reply("Try a Mezcal Margarita")
+	llm.OnRole(openai.ChatMessageRoleTool, `^\{"suggestions":\["Mezcal Margarita"\]\}$`).Reply("The waiter recommends a Mezcal Margarita")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"coordinator": {Model: "fake", AvailableFunctions: []string{"waiter"}},
+			"waiter": {
+				Model: "fake",
+				ResponseFormat: &appconfig.ResponseFormatConfig{Schema: map[string]interface{}{
+					"type":     "object",
+					"required": []string{"suggestions"},
+					"properties": map[string]interface{}{
+						"suggestions": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+					},
+				}},
+			},
+		},
+		FunctionCfg: map[string]*appconfig.FunctionConfig{
+			"waiter": {Description: "Asks the waiter"},
+		},
+	})
+
+	// the waiter keeps a session for the coordinator session
+	waiterSessions := map[string]interface {
+		httpSession
+		LastTurn() aiclient.TurnTrace
+	}{}
+	waiter := httptest.NewServer(newHttpRouter(t.Context(), func(sessionId string) (httpSession, error) {
+		session, err := aiclient.New("", sessionId, "waiter")
+		if err != nil {
+			return nil, err
+		}
+		waiterSessions[sessionId] = session
+		return session, nil
+	}))
+	defer waiter.Close()
+	appconfig.Current().FunctionCfg["waiter"].Url = waiter.URL + "/api/ask"
+
+	coordinator := httptest.NewServer(newHttpRouter(t.Context(), func(sessionId string) (httpSession, error) {
+		session, err := aiclient.New("", sessionId, "coordinator")
+		if err != nil {
+			return nil, err
+		}
+		return session, nil
+	}))
+	defer coordinator.Close()
+
+	status, data := postAskAs(t, coordinator.URL, "coordinator-session", "I'd like something smoky")
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %v", status, data)
+	}
+	if data.Content != "The waiter recommends a Mezcal Margarita" {
+		t.Errorf("unexpected answer: %+v", data)
+	}
+	if n := len(llm.Requests()); n != 4 {
+		t.Errorf("expected 4 model requests, got %d", n)
+	}
+	waiterAi := waiterSessions["coordinator-session"]
+	if waiterAi == nil || len(waiterSessions) != 1 {
+		t.Fatalf("expected a waiter session for the coordinator session, got %v", waiterSessions)
+	}
+	if turn := waiterAi.LastTurn(); turn.Repairs != 1 {
+		t.Errorf("expected 1 repair of the waiter answer, got %d", turn.Repairs)
+	}
+	// the coordinator's usage includes what the waiter reported
+	waiterUsage := data.Usage.Agents["waiter"]
+	if waiterUsage == nil || waiterUsage.Models["fake"].Calls.Count != 2 || data.Usage.TotalTokens <= waiterUsage.TotalTokens {
+		t.Errorf("expected waiter usage in coordinator usage, got %+v", data.Usage)
+	}
+
+	status, data = postAskAs(t, waiter.URL, "coordinator-session", "a cocktail with mezcal, again")
+	if status != http.StatusOK || string(data.Data) != `{"suggestions":["Mezcal Margarita"]}` {
+		t.Errorf("expected structured data, got %d: %+v", status, data)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, waiter.URL+"/api/usage", nil)
+	req.Header.Set(httptools.SessionIDHeader, "coordinator-session")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var report struct {
+		Session usage.Usage            `json:"session"`
+		Chats   map[string]usage.Usage `json:"chats"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("invalid usage JSON: %v", err)
+	}
+	if report.Session.Models["fake"].Calls.Count != 4 || report.Chats["coordinator"].Agents["waiter"] == nil {
+		t.Errorf("unexpected usage report: %+v", report)
+	}
+
+	resp, err = http.Get(coordinator.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{
+		`model_requests_total{model="fake",status="ok"}`,
+		`tool_calls_total{function="waiter",status="ok"}`,
+		`sessions_active{chat="coordinator"}`,
+		`http_requests_total{method="POST",route="/api/ask",code="200"}`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected %q in metrics:\n%s", want, body)
+		}
+	}
+}
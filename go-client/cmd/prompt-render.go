@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"go-client/lib/aiclient"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var promptRenderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Print the system prompt of a chat",
+	Run:   cmd_prompt_render,
+}
+
+var promptRenderSession string
+var promptRenderUser []string
+var promptRenderRetrieved string
+var promptRenderInput string
+
+func init() {
+	promptRenderCmd.Flags().StringVarP(&promptRenderSession, "session", "s", "render", "Session ID")
+	promptRenderCmd.Flags().StringArrayVarP(&promptRenderUser, "user", "u", nil, "User profile field key=value, can be repeated")
+	promptRenderCmd.Flags().StringVarP(&promptRenderRetrieved, "retrieved", "r", "", "Retrieved context")
+	promptRenderCmd.Flags().StringVarP(&promptRenderInput, "input", "i", "", "User message, selects few-shot examples as in a chat turn")
+	promptCmd.AddCommand(promptRenderCmd)
+}
+
+func cmd_prompt_render(cmd *cobra.Command, args []string) {
+	profile := map[string]string{}
+	for _, field := range promptRenderUser {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			log.Fatalf("invalid user profile field %q, expected key=value", field)
+		}
+		profile[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	request := aiclient.RequestPrompt{User: profile, Retrieved: promptRenderRetrieved}
+	prompt, err := aiclient.RenderSystemPrompt(cmd.Context(), chatName, promptRenderSession, request, promptRenderInput)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(prompt)
+}
@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Prompt tools",
+}
+
+func init() {
+	rootCmd.AddCommand(promptCmd)
+}
@@ -0,0 +1,424 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-client/lib/aiclient"
+	"go-client/lib/applog"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var replCmd = &cobra.Command{
+	Use:   "repl",
+	Short: "Chat in the terminal with streamed answers and tool calls shown inline",
+	Long: `Chat in the terminal with streamed answers and tool calls shown inline.
+
+Input ending with \ continues on the next line, """ starts and ends a multi-line block.
+!N sends input N from /history again, !! the last one. Commands:
+` + replHelp,
+	Run: cmd_repl,
+}
+
+const replHelp = `  /reset          start the conversation again
+  /save FILE      save the conversation to a JSON file
+  /load FILE      load a conversation saved with /save
+  /tools          list tools available to the model
+  /model [NAME]   show or change the model of this session
+  /system         show the system prompt
+  /reload         switch to the current config file
+  /history        list previous inputs
+  /help           show this help
+  /exit           quit`
+
+var replThink string
+var replHistory string
+var replLog string
+
+func init() {
+	replCmd.Flags().StringVar(&replThink, "think", thinkDim, "How <think> content is shown: show, dim, hide")
+	replCmd.Flags().StringVar(&replHistory, "history", ".repl_history", "Input history file, empty - no history")
+	replCmd.Flags().StringVar(&replLog, "log", "", "Log file, logs are discarded by default")
+	rootCmd.AddCommand(replCmd)
+}
+
+func cmd_repl(cmd *cobra.Command, args []string) {
+	if replThink != thinkShow && replThink != thinkDim && replThink != thinkHide {
+		log.Fatalf("invalid --think %q, expected show, dim or hide", replThink)
+	}
+	if replLog != "" {
+		f, err := os.OpenFile(replLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		applog.SetOutput(f)
+	} else {
+		applog.SetOutput(io.Discard)
+	}
+
+	r := newRepl(os.Stdout, replThink, isTerminal(os.Stdout))
+	r.historyFile = replHistory
+	r.loadHistory()
+	session, err := aiclient.New(cfgFile, uuid.NewString(), chatName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	session.WithStream(r.event)
+	watchConfig(cmd.Context())
+
+	fmt.Fprintf(os.Stdout, "Chat %s with %s, /help for commands\n", chatName, session.Model())
+	r.run(cmd.Context(), os.Stdin, session)
+}
+
+// replSession is the chat session used by the REPL.
+type replSession interface {
+	Ask(ctx context.Context, inputMsg string) (string, error)
+	Reset()
+	Reload() error
+	SystemPrompt() string
+	Tools() []aiclient.PromptTool
+	Model() string
+	SetModel(model string)
+	SaveHistory(path string) error
+	LoadHistory(path string) error
+}
+
+type repl struct {
+	out         io.Writer
+	think       *thinkWriter
+	color       bool
+	streamed    bool
+	history     []string
+	historyFile string
+}
+
+func newRepl(out io.Writer, thinkMode string, color bool) *repl {
+	return &repl{
+		out:   out,
+		think: &thinkWriter{w: out, mode: thinkMode, color: color},
+		color: color,
+	}
+}
+
+// run reads inputs until EOF, /exit or ctx is done.
+func (r *repl) run(ctx context.Context, in io.Reader, session replSession) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for ctx.Err() == nil {
+		input, ok := r.readInput(scanner)
+		if !ok {
+			return
+		}
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+
+		if strings.HasPrefix(input, "!") {
+			recalled, err := r.recall(input)
+			if err != nil {
+				fmt.Fprintln(r.out, err)
+				continue
+			}
+			fmt.Fprintln(r.out, recalled)
+			input = recalled
+		}
+		r.addHistory(input)
+
+		if strings.HasPrefix(input, "/") {
+			if !r.command(input, session) {
+				return
+			}
+			continue
+		}
+		r.ask(ctx, session, input)
+	}
+}
+
+// readInput reads one input: a line, lines joined by a trailing \ or a """ block.
+func (r *repl) readInput(scanner *bufio.Scanner) (string, bool) {
+	fmt.Fprint(r.out, r.style(styleBold, "you> "))
+	if !scanner.Scan() {
+		return "", false
+	}
+	line := scanner.Text()
+
+	if strings.TrimSpace(line) == `"""` {
+		lines := []string{}
+		for {
+			fmt.Fprint(r.out, "...  ")
+			if !scanner.Scan() {
+				return strings.Join(lines, "\n"), true
+			}
+			if strings.TrimSpace(scanner.Text()) == `"""` {
+				return strings.Join(lines, "\n"), true
+			}
+			lines = append(lines, scanner.Text())
+		}
+	}
+
+	lines := []string{}
+	for strings.HasSuffix(line, `\`) {
+		lines = append(lines, strings.TrimSuffix(line, `\`))
+		fmt.Fprint(r.out, "...  ")
+		if !scanner.Scan() {
+			return strings.Join(lines, "\n"), true
+		}
+		line = scanner.Text()
+	}
+	return strings.Join(append(lines, line), "\n"), true
+}
+
+func (r *repl) ask(ctx context.Context, session replSession, input string) {
+	r.streamed = false
+	fmt.Fprint(r.out, r.style(styleBold, chatName+"> "))
+	answer, err := session.Ask(ctx, input)
+	r.think.Flush()
+	if err != nil {
+		fmt.Fprintln(r.out)
+		fmt.Fprintln(r.out, r.style(styleRed, "error: "+err.Error()))
+		return
+	}
+	if !r.streamed {
+		r.think.Write(answer)
+		r.think.Flush()
+	}
+	fmt.Fprintln(r.out)
+}
+
+// event prints what the session streams.
+func (r *repl) event(e aiclient.Event) {
+	switch e.Type {
+	case aiclient.EventContent:
+		r.streamed = true
+		r.think.Write(e.Text)
+	case aiclient.EventToolCall:
+		r.think.Flush()
+		fmt.Fprintf(r.out, "\n%s\n", r.style(styleCyan, fmt.Sprintf("  ⚙ %s(%s)", e.Tool, e.Text)))
+	case aiclient.EventToolResult:
+		fmt.Fprintf(r.out, "%s\n", r.style(styleDim, fmt.Sprintf("  → %s: %s", e.Tool, shorten(e.Text, 300))))
+	}
+}
+
+// command runs a slash command, it returns false to quit.
+func (r *repl) command(input string, session replSession) bool {
+	name, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case "/exit", "/quit":
+		return false
+	case "/help":
+		fmt.Fprintln(r.out, replHelp)
+	case "/reset":
+		session.Reset()
+		fmt.Fprintln(r.out, "Conversation reset")
+	case "/save":
+		if arg == "" {
+			fmt.Fprintln(r.out, "usage: /save FILE")
+		} else if err := session.SaveHistory(arg); err != nil {
+			fmt.Fprintln(r.out, r.style(styleRed, "error: "+err.Error()))
+		} else {
+			fmt.Fprintf(r.out, "Conversation saved to %s\n", arg)
+		}
+	case "/load":
+		if arg == "" {
+			fmt.Fprintln(r.out, "usage: /load FILE")
+		} else if err := session.LoadHistory(arg); err != nil {
+			fmt.Fprintln(r.out, r.style(styleRed, "error: "+err.Error()))
+		} else {
+			fmt.Fprintf(r.out, "Conversation loaded from %s\n", arg)
+		}
+	case "/tools":
+		tools := session.Tools()
+		if len(tools) == 0 {
+			fmt.Fprintln(r.out, "No tools")
+		}
+		for _, t := range tools {
+			fmt.Fprintf(r.out, "  %s - %s\n", t.Name, t.Description)
+		}
+	case "/model":
+		if arg != "" {
+			session.SetModel(arg)
+		}
+		fmt.Fprintf(r.out, "Model: %s\n", session.Model())
+	case "/system":
+		fmt.Fprintln(r.out, session.SystemPrompt())
+	case "/reload":
+		if err := session.Reload(); err != nil {
+			fmt.Fprintln(r.out, r.style(styleRed, "error: "+err.Error()))
+			return true
+		}
+		fmt.Fprintf(r.out, "Config reloaded, model: %s\n", session.Model())
+	case "/history":
+		from := max(0, len(r.history)-20)
+		for i := from; i < len(r.history); i++ {
+			fmt.Fprintf(r.out, "%4d  %s\n", i+1, strings.ReplaceAll(r.history[i], "\n", "\n      "))
+		}
+	default:
+		fmt.Fprintf(r.out, "Unknown command %s, /help lists commands\n", name)
+	}
+	return true
+}
+
+// recall returns input !N from history, !! is the last one.
+func (r *repl) recall(input string) (string, error) {
+	if len(r.history) == 0 {
+		return "", fmt.Errorf("history is empty")
+	}
+	if input == "!!" {
+		return r.history[len(r.history)-1], nil
+	}
+	n, err := strconv.Atoi(input[1:])
+	if err != nil || n < 1 || n > len(r.history) {
+		return "", fmt.Errorf("no input %s in history", input)
+	}
+	return r.history[n-1], nil
+}
+
+// history file has one JSON string per line, inputs can be multi-line
+func (r *repl) loadHistory() {
+	if r.historyFile == "" {
+		return
+	}
+	f, err := os.Open(r.historyFile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var input string
+		if json.Unmarshal(scanner.Bytes(), &input) == nil {
+			r.history = append(r.history, input)
+		}
+	}
+}
+
+func (r *repl) addHistory(input string) {
+	r.history = append(r.history, input)
+	if r.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(r.historyFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	line, _ := json.Marshal(input)
+	f.Write(append(line, '\n'))
+}
+
+const (
+	styleBold = "\033[1m"
+	styleDim  = "\033[2m"
+	styleRed  = "\033[31m"
+	styleCyan = "\033[36m"
+	styleOff  = "\033[0m"
+)
+
+func (r *repl) style(style string, text string) string {
+	if !r.color {
+		return text
+	}
+	return style + text + styleOff
+}
+
+const (
+	thinkShow = "show"
+	thinkDim  = "dim"
+	thinkHide = "hide"
+)
+
+// thinkWriter writes streamed answers, <think> blocks are shown as they are,
+// dimmed or hidden. A tag can be split between writes.
+type thinkWriter struct {
+	w       io.Writer
+	mode    string
+	color   bool
+	inThink bool
+	pending string // a possible start of a tag
+}
+
+func (t *thinkWriter) Write(text string) {
+	text = t.pending + text
+	t.pending = ""
+	for text != "" {
+		tag := "<think>"
+		if t.inThink {
+			tag = "</think>"
+		}
+		if i := strings.Index(text, tag); i >= 0 {
+			t.out(text[:i])
+			if t.inThink {
+				t.out(tag)
+				t.inThink = false
+			} else {
+				t.inThink = true
+				t.out(tag)
+			}
+			text = text[i+len(tag):]
+			continue
+		}
+		keep := partialTag(text, tag)
+		t.out(text[:len(text)-keep])
+		t.pending = text[len(text)-keep:]
+		return
+	}
+}
+
+// Flush writes text kept as a possible tag, at the end of an answer.
+func (t *thinkWriter) Flush() {
+	t.out(t.pending)
+	t.pending = ""
+	t.inThink = false
+}
+
+func (t *thinkWriter) out(text string) {
+	if text == "" {
+		return
+	}
+	if t.inThink {
+		switch {
+		case t.mode == thinkHide:
+			return
+		case t.mode == thinkDim && t.color:
+			text = styleDim + text + styleOff
+		}
+	}
+	io.WriteString(t.w, text)
+}
+
+// partialTag returns the length of the longest text suffix being a tag prefix.
+func partialTag(text string, tag string) int {
+	for n := min(len(tag)-1, len(text)); n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+func shorten(text string, maxLen int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len([]rune(text)) > maxLen {
+		return string([]rune(text)[:maxLen]) + "…"
+	}
+	return text
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"go-client/lib/aiclient"
+	"go-client/lib/appconfig"
+	"go-client/lib/fakellm"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestThinkWriter(t *testing.T) {
+	chunks := []string{"<th", "ink>let me", " think</thi", "nk>\n\nA <b>", "Negroni"}
+	tests := []struct {
+		mode  string
+		color bool
+		want  string
+	}{
+		{thinkShow, true, "<think>let me think</think>\n\nA <b>Negroni"},
+		{thinkHide, true, "\n\nA <b>Negroni"},
+		{thinkDim, false, "<think>let me think</think>\n\nA <b>Negroni"},
+		{thinkDim, true, styleDim + "<think>" + styleOff + styleDim + "let me" + styleOff + styleDim + " think" + styleOff + styleDim + "</think>" + styleOff + "\n\nA <b>Negroni"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			var out strings.Builder
+			w := &thinkWriter{w: &out, mode: tt.mode, color: tt.color}
+			for _, chunk := range chunks {
+				w.Write(chunk)
+			}
+			w.Flush()
+			if out.String() != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, out.String())
+			}
+		})
+	}
+}
+
+func TestRepl(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`(?i)what time`).CallTool("get_current_time", `{}`).Once()
+	llm.OnRole(openai.ChatMessageRoleTool, `.`).Reply("It is late")
+	llm.On(`(?s)first line\nsecond line`).Reply("Got two lines")
+	llm.On(`.`).Reply("<think>hmm</think>Hello there")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"coordinator": {Model: "fake", AvailableFunctions: []string{"get_current_time"}},
+		},
+	})
+
+	saved := filepath.Join(t.TempDir(), "chat.json")
+	input := strings.Join([]string{
+		"hello",
+		"what time is it?",
+		`"""`, "first line", "second line", `"""`,
+		"/tools",
+		"/model other",
+		"/save " + saved,
+		"/reset",
+		"!1",
+		"/exit",
+		"never sent",
+	}, "\n")
+
+	var out strings.Builder
+	r := newRepl(&out, thinkHide, false)
+	session, err := aiclient.New("", "repl-session", "coordinator")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	session.WithStream(r.event)
+	r.run(context.Background(), strings.NewReader(input), session)
+
+	for _, want := range []string{
+		"coordinator> Hello there\n",
+		"⚙ get_current_time({})",
+		"→ get_current_time: ",
+		"It is late\n",
+		"Got two lines\n",
+		"get_current_time - Get the current time",
+		"Model: other\n",
+		"Conversation saved to " + saved,
+		"Conversation reset\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "hmm") {
+		t.Errorf("expected think content hidden, got:\n%s", out.String())
+	}
+
+	requests := llm.Requests()
+	last := requests[len(requests)-1]
+	if last.Model != "other" || !last.Stream || len(last.Messages) != 2 || last.Messages[1].Content != "hello" {
+		t.Errorf("expected recalled input streamed to the new model after reset, got: %+v", last)
+	}
+	if len(r.history) != 9 {
+		t.Errorf("expected 9 inputs in history, got %d: %q", len(r.history), r.history)
+	}
+}
@@ -1,11 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"go-client/lib/aiclient"
 	"go-client/lib/appconfig"
+	"go-client/lib/applog"
 
 	"github.com/spf13/cobra"
 )
@@ -15,12 +21,29 @@ var rootCmd = &cobra.Command{
 	Short: "My AI sandbox",
 	Long:  "A simple of AI sandbox",
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// flags until the config is read, then with the "log" block
+		if err := setupLogging(nil); err != nil {
+			log.Fatal(err)
+			return err
+		}
+		// flags apply also to configs reloaded later
+		appconfig.AddOverride(func(cfg *appconfig.AppConfig) {
+			if recordDir != "" {
+				cfg.Cassette = &appconfig.CassetteConfig{Mode: aiclient.CassetteRecord, Dir: recordDir}
+			}
+			if replayFile != "" {
+				cfg.Cassette = &appconfig.CassetteConfig{Mode: aiclient.CassetteReplay, File: replayFile}
+			}
+		})
 		if err := appconfig.LoadConfig(cfgFile); err != nil {
 			log.Fatal(err)
 			return err
 		}
-		log.Printf("Check if configuration for chat \"%s\" exists", chatName)
-		if _, ok := appconfig.AppCfg.AiChatCfg[chatName]; !ok {
+		if err := setupLogging(appconfig.Current()); err != nil {
+			log.Fatal(err)
+			return err
+		}
+		if _, ok := appconfig.Current().AiChatCfg[chatName]; !ok {
 			err := fmt.Errorf("Configuration for chat \"%s\" not found", chatName)
 			log.Fatal(err)
 			return err
@@ -31,14 +54,49 @@ var rootCmd = &cobra.Command{
 
 var cfgFile string
 var chatName string
+var recordDir string
+var replayFile string
+var logLevel string
+var logFormat string
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "config.yaml", "Config file path")
 	rootCmd.PersistentFlags().StringVarP(&chatName, "chat", "", "coordinator", "Chat name in config file")
+	rootCmd.PersistentFlags().StringVarP(&recordDir, "record", "", "", "Record chat sessions to cassette files in this directory")
+	rootCmd.PersistentFlags().StringVarP(&replayFile, "replay", "", "", "Replay a recorded cassette file instead of calling the model and tools")
+	rootCmd.MarkFlagsMutuallyExclusive("record", "replay")
+	rootCmd.PersistentFlags().StringVarP(&logLevel, "log-level", "", "", "Log level: debug, info, warn, error (overrides log.level in config)")
+	rootCmd.PersistentFlags().StringVarP(&logFormat, "log-format", "", "", "Log format: text, json (overrides log.format in config)")
+}
+
+// setupLogging applies the "log" block of cfg, log flags take precedence.
+func setupLogging(cfg *appconfig.AppConfig) error {
+	logCfg := cfg.LogConfig()
+	if logLevel != "" {
+		logCfg.Level = logLevel
+	}
+	if logFormat != "" {
+		logCfg.Format = logFormat
+	}
+	return applog.Setup(logCfg)
+}
+
+// watchConfig reloads the config file in the background until ctx is done,
+// new sessions get the new config, logging follows it at once.
+func watchConfig(ctx context.Context) {
+	w := appconfig.NewWatcher(cfgFile).OnReload(func(cfg *appconfig.AppConfig) {
+		if err := setupLogging(cfg); err != nil {
+			slog.Error("logging config not applied", "err", err)
+		}
+	})
+	go w.Run(ctx)
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -4,164 +4,673 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go-client/lib/appconfig"
+	"go-client/lib/applog"
+	"go-client/lib/examples"
 	"go-client/lib/httptools"
+	"go-client/lib/metrics"
+	"go-client/lib/usage"
 	"io"
-	"log"
+	"log/slog"
+	"maps"
 	"net/http"
-	"os"
+	"path/filepath"
 	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	openai "github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
 type aiclient struct {
-	ctx       context.Context
 	baseURL   string
 	apiToken  string
 	sessionId string
+	chatName  string
 	client    *openai.Client
 	messages  []openai.ChatCompletionMessage
 	tools     []openai.Tool
+	appCfg    *appconfig.AppConfig // taken at start, replaced by Reload
 	cfg       *appconfig.AiChatConfig
-}
+	cassette  *Cassette
 
-func New(cfgFile string, sessionId string, chatName string) *aiclient {
+	// one turn or reload at a time, they share the history
+	mu sync.Mutex
 
-	log.Printf("New session with ID %s started", sessionId)
+	stream func(Event) // see WithStream
 
-	apiToken := os.Getenv("OPENAI_API_TOKEN")
-	if apiToken == "" {
-		apiToken = "DefaultToken"
+	promptTmpl *PromptTemplate
+	user       map[string]string
+	retrieved  string
+	turnPrompt RequestPrompt // of the current turn, see WithRequestPrompt
 
-	}
+	responseSchema *jsonschema.Definition
+
+	exampleStore examples.ExampleStore
+	examples     []examples.Example
+
+	validationFailures atomic.Int32
+	turn               TurnTrace
+
+	// read by Usage while a turn runs
+	usageMu      sync.Mutex
+	sessionUsage usage.Usage
+
+	closed atomic.Bool
+}
 
+// ToolTrace is a tool call made while answering a user message.
+type ToolTrace struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Result    string `json:"result"`
+}
+
+// ErrBudgetExceeded stops a turn that used up the chat's token budget.
+var ErrBudgetExceeded = errors.New("token budget exceeded")
+
+// TurnTrace describes how the last answer was produced.
+type TurnTrace struct {
+	ToolCalls  []ToolTrace `json:"toolCalls"`
+	ToolRounds int         `json:"toolRounds"`
+	Repairs    int         `json:"repairs,omitempty"` // repair retries of a structured answer
+	// tool calls rejected for an unknown function or invalid arguments
+	ValidationFailures int         `json:"validationFailures,omitempty"`
+	Usage              usage.Usage `json:"usage"` // of all model, tool and store calls of the turn
+}
+
+const (
+	defaultToolConcurrency = 4
+	defaultMaxToolRounds   = 5
+)
+
+// New starts a session of chatName with the running config. It fails when
+// the chat isn't configured or its prompt, response format or cassette can't
+// be set up, the caller decides if that ends the process.
+func New(cfgFile string, sessionId string, chatName string) (*aiclient, error) {
 	a := &aiclient{
-		ctx:       context.Background(),
-		baseURL:   os.Getenv("OPENAI_URL"),
-		apiToken:  apiToken,
 		sessionId: sessionId,
-		cfg:       appconfig.AppCfg.AiChatCfg[chatName],
+		chatName:  chatName,
+		appCfg:    appconfig.Current(),
+	}
+	cfg, ok := a.appCfg.AiChatCfg[chatName]
+	if !ok || cfg == nil {
+		return nil, fmt.Errorf("Configuration for chat \"%s\" not found", chatName)
 	}
+	a.cfg = cfg
 
-	a.initAiClient()
+	a.initApiClient()
+	if err := a.initCassette(chatName); err != nil {
+		return nil, err
+	}
+	if err := a.initChat(); err != nil {
+		return nil, fmt.Errorf("chat %s: %w", chatName, err)
+	}
+	a.logger().Info("session started")
+	metrics.ActiveSessions.Inc(chatName)
+	return a, nil
+}
+
+// logger returns the session's logger, lines carry the session ID and chat name.
+func (a *aiclient) logger() *slog.Logger {
+	return logger().With(applog.SessionKey, a.sessionId, applog.ChatKey, a.chatName)
+}
+
+// logger returns the package logger, use it with the context of a turn.
+func logger() *slog.Logger {
+	return applog.Component("aiclient")
+}
+
+// WithCassette records the session to c or replays it from c.
+func (a *aiclient) WithCassette(c *Cassette) *aiclient {
+	a.cassette = c
 	return a
 }
 
-func (a *aiclient) initAiClient() {
+func (a *aiclient) initApiClient() {
+	api := a.appCfg.OpenAIAPI()
+	a.apiToken = api.Token.Value()
+	if a.apiToken == "" {
+		a.apiToken = "DefaultToken"
+	}
+	a.baseURL = api.URL
+
 	config := openai.DefaultConfig(a.apiToken)
 	if a.baseURL != "" {
 		config.BaseURL = a.baseURL
 	}
 	a.client = openai.NewClientWithConfig(config)
+}
+
+// initCassette starts recording or replaying the session as set in "cassette" config.
+// Recordings are written to <dir>/<chat>-<session>.jsonl.
+func (a *aiclient) initCassette(chatName string) error {
+	cfg := a.appCfg.Cassette
+	if cfg == nil || cfg.Mode == "" {
+		return nil
+	}
+
+	var err error
+	switch cfg.Mode {
+	case CassetteRecord:
+		a.cassette, err = NewRecorder(filepath.Join(cfg.Dir, fmt.Sprintf("%s-%s.jsonl", chatName, a.sessionId)))
+	case CassetteReplay:
+		a.cassette, err = LoadCassette(cfg.File)
+	default:
+		err = fmt.Errorf("unknown cassette mode %q", cfg.Mode)
+	}
+	if err != nil {
+		return err
+	}
+	a.logger().Info("cassette", "mode", cfg.Mode, "file", a.cassette.Path())
+	return nil
+}
 
-	promptBuilder := PromptBuilder()
-	prompt := promptBuilder.
-		WithRole(a.cfg.Prompt.Role).
-		WithContext(a.cfg.Prompt.Context).
-		WithExamples(a.cfg.Prompt.Examples).
-		WithTask(a.cfg.Prompt.Task).
-		WithInstructions(a.cfg.Prompt.Instructions).
-		Get()
+// initChat sets up tools, prompt and response format from the chat config.
+func (a *aiclient) initChat() error {
+	// tools first, the prompt can list them
+	a.tools = nil
+	a.defineTools()
+
+	promptTmpl, err := chatPromptTemplate(a.appCfg, a.chatName)
+	if err != nil {
+		return err
+	}
+	a.promptTmpl = promptTmpl
+	if err := a.initResponseFormat(); err != nil {
+		return err
+	}
 
 	// Create Prompt
-	a.messages = append(a.messages, openai.ChatCompletionMessage{
-		Role:    "system",
-		Content: prompt,
-	})
+	return a.renderSystemPrompt()
+}
+
+// Reload switches the session to the running config: model, prompt and tools
+// change, the history is kept. On error the session keeps its config.
+func (a *aiclient) Reload() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	appCfg := appconfig.Current()
+	if appCfg == a.appCfg {
+		return nil
+	}
+	cfg, ok := appCfg.AiChatCfg[a.chatName]
+	if !ok {
+		return fmt.Errorf("Configuration for chat \"%s\" not found", a.chatName)
+	}
+
+	prevAppCfg, prevCfg, prevTools, prevTmpl, prevSchema := a.appCfg, a.cfg, a.tools, a.promptTmpl, a.responseSchema
+	a.appCfg, a.cfg = appCfg, cfg
+	if err := a.initChat(); err != nil {
+		a.appCfg, a.cfg, a.tools, a.promptTmpl, a.responseSchema = prevAppCfg, prevCfg, prevTools, prevTmpl, prevSchema
+		return err
+	}
+	a.initApiClient()
+	// the example store depends on the store config
+	a.exampleStore = nil
+	a.logger().Info("session reloaded config")
+	return nil
+}
+
+func (a *aiclient) promptData() PromptData {
+	now := time.Now()
+	data := PromptData{
+		Date:      now.Format(time.DateOnly),
+		Now:       now,
+		SessionID: a.sessionId,
+		Chat:      a.chatName,
+		User:      a.user,
+		Retrieved: a.retrieved,
+		Examples:  a.examples,
+		Vars:      a.cfg.Prompt.Vars,
+	}
+	if len(a.turnPrompt.User) > 0 {
+		data.User = maps.Clone(a.user)
+		if data.User == nil {
+			data.User = map[string]string{}
+		}
+		maps.Copy(data.User, a.turnPrompt.User)
+	}
+	if a.turnPrompt.Retrieved != "" {
+		data.Retrieved = a.turnPrompt.Retrieved
+	}
+	for _, t := range a.tools {
+		if t.Function != nil {
+			data.Tools = append(data.Tools, PromptTool{Name: t.Function.Name, Description: t.Function.Description})
+		}
+	}
+	return data
+}
+
+// renderSystemPrompt renders the prompt templates into the first message of the history.
+func (a *aiclient) renderSystemPrompt() error {
+	prompt, err := a.promptTmpl.Render(a.promptData())
+	if err != nil {
+		return err
+	}
+
+	msg := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: prompt}
+	if len(a.messages) > 0 && a.messages[0].Role == openai.ChatMessageRoleSystem {
+		a.messages[0] = msg
+	} else {
+		a.messages = append([]openai.ChatCompletionMessage{msg}, a.messages...)
+	}
+	return nil
+}
 
+// SystemPrompt returns the current system prompt.
+func (a *aiclient) SystemPrompt() string {
+	if len(a.messages) == 0 {
+		return ""
+	}
+	return a.messages[0].Content
+}
+
+// RenderSystemPrompt renders the system prompt of chatName with the config of
+// ctx as a session would for a user message with request prompt data, without
+// starting a session: no cassette, no session metrics. input selects few-shot
+// examples, none are selected when it is empty.
+func RenderSystemPrompt(ctx context.Context, chatName string, sessionId string, prompt RequestPrompt, input string) (string, error) {
+	a := &aiclient{
+		sessionId:  sessionId,
+		chatName:   chatName,
+		appCfg:     appconfig.FromContext(ctx),
+		turnPrompt: prompt,
+	}
+	cfg, ok := a.appCfg.AiChatCfg[chatName]
+	if !ok || cfg == nil {
+		return "", fmt.Errorf("Configuration for chat \"%s\" not found", chatName)
+	}
+	a.cfg = cfg
 	a.defineTools()
 
-	// log.Printf("Available functions: %s", a.cfg.AvailableFunctions)
+	promptTmpl, err := chatPromptTemplate(a.appCfg, chatName)
+	if err != nil {
+		return "", err
+	}
+	if input != "" {
+		a.selectExamples(appconfig.WithConfig(ctx, a.appCfg), input)
+	}
+	return promptTmpl.Render(a.promptData())
+}
+
+// WithUserProfile sets the user profile available in prompt templates as .User.
+func (a *aiclient) WithUserProfile(profile map[string]string) *aiclient {
+	a.user = profile
+	if err := a.renderSystemPrompt(); err != nil {
+		a.logger().Error("prompt rendering failed", "err", err)
+	}
+	return a
+}
+
+// selectExamples retrieves the examples most similar to the user message for
+// this turn's prompt. Failures only drop the examples, the chat works without them.
+func (a *aiclient) selectExamples(ctx context.Context, inputMsg string) {
+	a.examples = nil
+	if a.cfg.Examples.K <= 0 || a.cassette.replaying() {
+		return
+	}
+
+	if a.exampleStore == nil {
+		store, err := OpenExampleStore(ctx, a.chatName)
+		if err != nil {
+			a.logger().WarnContext(ctx, "example store unavailable, answering without examples", "err", err)
+			return
+		}
+		a.exampleStore = store
+	}
+
+	start := time.Now()
+	similar, err := a.exampleStore.Similar(ctx, inputMsg, a.cfg.Examples.K, a.cfg.Examples.Distance)
+	recordStore(ctx, "examples", start)
+	if err != nil {
+		a.logger().WarnContext(ctx, "example selection failed, answering without examples", "err", err)
+		return
+	}
+	for _, ex := range similar {
+		a.examples = append(a.examples, ex.Example)
+	}
+	a.logger().DebugContext(ctx, "examples selected", "count", len(a.examples))
+}
+
+// Answer is an answer to a user message with how it was produced.
+type Answer struct {
+	Content    string
+	Structured bool // Content is JSON valid against the chat's response format
+	Turn       TurnTrace
 }
 
-func (a *aiclient) Ask(inputMsg string) (string, error) {
-	log.Printf("Sending request to AI: %s", inputMsg)
+// Ask sends a user message to the model and returns its answer.
+// Cancelling ctx stops the model request and any tool calls in progress.
+func (a *aiclient) Ask(ctx context.Context, inputMsg string) (string, error) {
+	answer, err := a.AskTurn(ctx, inputMsg)
+	return answer.Content, err
+}
+
+// AskTurn is Ask returning also the trace of the turn, failed turns included,
+// taken before another turn can start.
+func (a *aiclient) AskTurn(ctx context.Context, inputMsg string) (Answer, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	content, err := a.ask(ctx, inputMsg)
+	return Answer{Content: content, Structured: a.responseSchema != nil, Turn: a.turn}, err
+}
+
+func (a *aiclient) ask(ctx context.Context, inputMsg string) (string, error) {
+	// tools read the session's config, not the running one
+	ctx = appconfig.WithConfig(ctx, a.appCfg)
+	// a trace ID given by the caller follows the request to other agents
+	ctx = applog.WithAttrs(ctx, applog.SessionKey, a.sessionId, applog.ChatKey, a.chatName)
+	if applog.TraceID(ctx) == "" {
+		ctx = applog.WithTraceID(ctx, uuid.NewString())
+	}
+
+	a.logger().InfoContext(ctx, "turn started")
+	a.logger().DebugContext(ctx, "user message", "content", inputMsg)
+
+	a.turn = TurnTrace{}
+	// failed turns are accounted too, they cost as much
+	rec := usage.NewRecorder()
+	ctx = usage.NewContext(ctx, rec)
+	defer a.recordTurn(rec)
 
+	a.selectExamples(ctx, inputMsg)
+	a.turnPrompt = requestPromptFrom(ctx)
+	// templates may depend on the current time, selected examples and request data
+	if err := a.renderSystemPrompt(); err != nil {
+		return "", err
+	}
+	historyLen := len(a.messages)
 	a.messages = append(a.messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: string(inputMsg)})
 	response, err := a.request(
+		ctx,
 		openai.ChatCompletionRequest{
-			Model:       a.cfg.Model,
-			Temperature: a.cfg.Temperature,
-			Messages:    a.messages,
-			Tools:       a.tools,
-			ToolChoice:  "auto",
+			Model:          a.cfg.Model,
+			Temperature:    a.cfg.Temperature,
+			Messages:       a.messages,
+			Tools:          a.tools,
+			ToolChoice:     "auto",
+			ResponseFormat: a.responseFormat(),
 		},
 	)
 	if err != nil {
-		log.Fatalf("Sending request ERROR: %v", err)
+		a.logger().ErrorContext(ctx, "turn failed", "err", err)
+		// drop the unfinished turn, so the next request doesn't
+		// carry tool calls without results
+		a.messages = a.messages[:historyLen]
 		return "", err
 	}
 
-	return response.Choices[0].Message.Content, nil
+	answer := response.Choices[0].Message.Content
+	if a.responseSchema != nil {
+		answer, err = a.structuredAnswer(ctx, answer)
+		if err != nil {
+			a.logger().ErrorContext(ctx, "structured answer failed", "err", err)
+			a.messages = a.messages[:historyLen]
+			return "", err
+		}
+	}
+	return answer, nil
 }
 
-func (a *aiclient) request(request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
-	var err error
-	var response openai.ChatCompletionResponse
-
-	response, err = a.client.CreateChatCompletion(a.ctx, request)
-	if err != nil {
-		return openai.ChatCompletionResponse{}, err
-	}
+func (a *aiclient) recordTurn(rec *usage.Recorder) {
+	a.turn.Usage = rec.Usage()
+	a.usageMu.Lock()
+	a.sessionUsage.Add(a.turn.Usage)
+	a.usageMu.Unlock()
+	usage.Record(a.chatName, a.turn.Usage)
+}
 
-	respMsg := response.Choices[0].Message
-	log.Printf("Response from AI: %s", respMsg.Content)
+// Usage returns what the session used so far.
+func (a *aiclient) Usage() usage.Usage {
+	a.usageMu.Lock()
+	defer a.usageMu.Unlock()
+	return a.sessionUsage.Clone()
+}
 
-	a.messages = append(a.messages, respMsg)
+// Structured tells if answers are JSON validated against the chat's response format.
+func (a *aiclient) Structured() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.responseSchema != nil
+}
 
-	if len(respMsg.ToolCalls) > 0 {
-		log.Println("Start using tools")
-		response, err = a.handleToolCalls(respMsg.ToolCalls)
+// request sends the conversation to the model and runs the tools it calls,
+// until it answers without tool calls. After maxToolRounds the tools are
+// withheld, so the model has to answer with what it got.
+func (a *aiclient) request(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	for {
+		if a.turn.ToolRounds >= a.maxToolRounds() {
+			request.Tools = nil
+			request.ToolChoice = nil
+		}
+		response, err := a.createChatCompletion(ctx, request)
 		if err != nil {
 			return openai.ChatCompletionResponse{}, err
 		}
-	}
 
-	return response, err
-}
+		respMsg := response.Choices[0].Message
+		a.logger().DebugContext(ctx, "model response", "content", respMsg.Content)
 
-func (a *aiclient) handleToolCalls(toolCalls []openai.ToolCall) (openai.ChatCompletionResponse, error) {
-	for _, toolCall := range toolCalls {
-		log.Printf("Call function: %s(#%v)", toolCall.Function.Name, toolCall.Function.Arguments)
-		result, err := a.callFunction(toolCall)
-		if err != nil {
+		a.messages = append(a.messages, respMsg)
+
+		if len(respMsg.ToolCalls) == 0 {
+			return response, nil
+		}
+		if request.Tools == nil {
+			return openai.ChatCompletionResponse{}, fmt.Errorf("model still calls tools after %d rounds", a.turn.ToolRounds)
+		}
+
+		a.logger().DebugContext(ctx, "model called tools", "count", len(respMsg.ToolCalls))
+		if err := a.handleToolCalls(ctx, respMsg.ToolCalls); err != nil {
 			return openai.ChatCompletionResponse{}, err
 		}
-		log.Printf("Function result (%s): %s", toolCall.Function.Name, result)
+		a.logger().DebugContext(ctx, "sending tool results to model")
+		request.Messages = a.messages
+	}
+}
+
+func (a *aiclient) handleToolCalls(ctx context.Context, toolCalls []openai.ToolCall) error {
+	for _, toolCall := range toolCalls {
+		a.emit(Event{Type: EventToolCall, Tool: toolCall.Function.Name, Text: toolCall.Function.Arguments})
+	}
+	failures := a.validationFailures.Load()
+	results, err := a.runToolCalls(ctx, toolCalls)
+	a.turn.ValidationFailures += int(a.validationFailures.Load() - failures)
+	if err != nil {
+		return err
+	}
+	for i, toolCall := range toolCalls {
+		a.emit(Event{Type: EventToolResult, Tool: toolCall.Function.Name, Text: results[i]})
+	}
+
+	// results are appended in the order the model requested them
+	a.turn.ToolRounds++
+	for i, toolCall := range toolCalls {
 		a.messages = append(a.messages, openai.ChatCompletionMessage{
 			Role:       openai.ChatMessageRoleTool,
-			Content:    result,
+			Content:    results[i],
 			Name:       toolCall.Function.Name,
 			ToolCallID: toolCall.ID,
 		})
+		a.turn.ToolCalls = append(a.turn.ToolCalls, ToolTrace{
+			Name:      toolCall.Function.Name,
+			Arguments: toolCall.Function.Arguments,
+			Result:    results[i],
+		})
 	}
+	return nil
+}
 
-	log.Printf("Sending request to AI with results(s) from tool(s)")
-
-	response, err := a.client.CreateChatCompletion(
-		a.ctx,
-		openai.ChatCompletionRequest{
-			Model:       a.cfg.Model,
-			Temperature: a.cfg.Temperature,
-			Messages:    a.messages,
-		},
-	)
+// createChatCompletion calls the model, or serves the recorded response when replaying.
+func (a *aiclient) createChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	if err := a.checkBudget(ctx); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
 
-	log.Printf("Response from AI with result(s) from tool(s): %s\n", response.Choices[0].Message.Content)
+	start := time.Now()
+	if a.cassette.replaying() {
+		response, err := a.cassette.replayModel(request)
+		a.recordModel(ctx, request.Model, response.Usage, time.Since(start))
+		if err == nil && len(response.Choices) > 0 && response.Choices[0].Message.Content != "" {
+			a.emit(Event{Type: EventContent, Text: response.Choices[0].Message.Content})
+		}
+		return response, err
+	}
 
+	var response openai.ChatCompletionResponse
+	var err error
+	if a.stream != nil {
+		response, err = a.streamChatCompletion(ctx, request)
+	} else {
+		response, err = a.client.CreateChatCompletion(ctx, request)
+	}
+	if err == nil && len(response.Choices) == 0 {
+		err = fmt.Errorf("no choices in model response")
+	}
+	metrics.ObserveModel(request.Model, response.Usage.PromptTokens, response.Usage.CompletionTokens, time.Since(start), err)
+	a.recordModel(ctx, request.Model, response.Usage, time.Since(start))
+	if a.cassette.recording() {
+		if recErr := a.cassette.recordModel(request, response, err); recErr != nil {
+			a.logger().ErrorContext(ctx, "cassette recording failed", "err", recErr)
+		}
+	}
 	return response, err
 }
 
+// recordModel adds a model request to the usage of ctx, priced by the "pricing" config.
+func (a *aiclient) recordModel(ctx context.Context, model string, u openai.Usage, duration time.Duration) {
+	cost := a.appCfg.Pricing[model].Cost(u.PromptTokens, u.CompletionTokens)
+	tokens := usage.Tokens{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+	usage.FromContext(ctx).Model(model, tokens, duration, cost)
+}
+
+// checkBudget stops model requests once the chat's token budget is used up.
+func (a *aiclient) checkBudget(ctx context.Context) error {
+	budget := a.cfg.Budget
+	used := usage.FromContext(ctx).TotalTokens()
+	if budget.TurnTokens > 0 && used >= budget.TurnTokens {
+		return fmt.Errorf("%w: %d of %d tokens per turn used", ErrBudgetExceeded, used, budget.TurnTokens)
+	}
+	if budget.SessionTokens > 0 {
+		a.usageMu.Lock()
+		used += a.sessionUsage.TotalTokens
+		a.usageMu.Unlock()
+		if used >= budget.SessionTokens {
+			return fmt.Errorf("%w: %d of %d tokens per session used", ErrBudgetExceeded, used, budget.SessionTokens)
+		}
+	}
+	return nil
+}
+
+// recordStore adds a store query started at start to the usage of ctx and to metrics.
+func recordStore(ctx context.Context, query string, start time.Time) {
+	duration := time.Since(start)
+	usage.FromContext(ctx).Store(duration)
+
+	store := StoreWeaviate
+	if cfg := appconfig.FromContext(ctx).Store; cfg != nil && cfg.Type != "" {
+		store = cfg.Type
+	}
+	metrics.StoreQueryDuration.Observe(duration.Seconds(), store, query)
+}
+
+// runToolCalls executes independent tool calls concurrently, at most
+// toolConcurrency at a time. A failing call doesn't stop the others, its
+// error is its result, so the model can retry or answer without it. Only
+// cancelling ctx stops the calls and fails the turn.
+func (a *aiclient) runToolCalls(ctx context.Context, toolCalls []openai.ToolCall) ([]string, error) {
+	results := make([]string, len(toolCalls))
+	sem := make(chan struct{}, a.toolConcurrency())
+
+	var wg sync.WaitGroup
+	for i, toolCall := range toolCalls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+
+			result, err := a.runToolCall(ctx, toolCall)
+			if err != nil {
+				a.logger().WarnContext(ctx, "tool call failed", applog.ToolKey, toolCall.Function.Name, "err", err)
+				result = fmt.Sprintf("Error: function %s failed: %v", toolCall.Function.Name, err)
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (a *aiclient) runToolCall(ctx context.Context, toolCall openai.ToolCall) (string, error) {
+	ctx = applog.WithAttrs(ctx, applog.ToolKey, toolCall.Function.Name)
+	a.logger().InfoContext(ctx, "tool call")
+	a.logger().DebugContext(ctx, "tool arguments", "arguments", toolCall.Function.Arguments)
+	start := time.Now()
+	defer func() { usage.FromContext(ctx).Tool(time.Since(start)) }()
+	if a.cassette.replaying() {
+		return a.cassette.replayTool(toolCall)
+	}
+
+	result, err := a.executeToolCall(ctx, toolCall)
+	metrics.ObserveToolCall(toolCall.Function.Name, time.Since(start), err)
+	if a.cassette.recording() {
+		if recErr := a.cassette.recordTool(toolCall, result, err); recErr != nil {
+			a.logger().ErrorContext(ctx, "cassette recording failed", "err", recErr)
+		}
+	}
+	return result, err
+}
+
+func (a *aiclient) executeToolCall(ctx context.Context, toolCall openai.ToolCall) (string, error) {
+	result := a.validateToolCall(toolCall)
+	if result == "" {
+		var err error
+		result, err = a.callFunction(ctx, toolCall)
+		if err != nil {
+			return "", err
+		}
+	}
+	a.logger().DebugContext(ctx, "tool result", "result", result)
+	return result, nil
+}
+
+func (a *aiclient) toolConcurrency() int {
+	if a.cfg.ToolConcurrency > 0 {
+		return a.cfg.ToolConcurrency
+	}
+	return defaultToolConcurrency
+}
+
+func (a *aiclient) maxToolRounds() int {
+	if a.cfg.MaxToolRounds > 0 {
+		return a.cfg.MaxToolRounds
+	}
+	return defaultMaxToolRounds
+}
+
 func (a *aiclient) defineTools() {
 
 	// built-in functions
 	for _, f := range toolFunctions {
 		if slices.Contains(a.cfg.AvailableFunctions, f.definition.Name) {
-			log.Println("Available function:", f.definition.Name)
+			a.logger().Debug("built-in tool available", applog.ToolKey, f.definition.Name)
 			a.tools = append(
 				a.tools,
 				openai.Tool{
@@ -173,9 +682,9 @@ func (a *aiclient) defineTools() {
 	}
 
 	// API based functions
-	for k, f := range appconfig.AppCfg.FunctionCfg {
+	for k, f := range a.appCfg.FunctionCfg {
 		if slices.Contains(a.cfg.AvailableFunctions, k) {
-			log.Printf("API based function: %s (%s)", k, f.Url)
+			a.logger().Debug("agent tool available", applog.ToolKey, k, "url", f.Url)
 
 			functionDefinition := &openai.FunctionDefinition{
 				Name:        k,
@@ -204,38 +713,101 @@ func (a *aiclient) defineTools() {
 
 }
 
-func (a *aiclient) callFunction(toolCall openai.ToolCall) (string, error) {
+// validateToolCall checks the called function is available and its arguments
+// match the declared tool schema. When they don't, it returns a message for
+// the model instead of a function result, so the model can retry the call.
+func (a *aiclient) validateToolCall(toolCall openai.ToolCall) string {
+	definition := a.toolDefinition(toolCall.Function.Name)
+	if definition == nil {
+		a.validationFailures.Add(1)
+		metrics.ToolValidationFailures.Inc("unknown")
+		a.logger().Warn("unknown tool", applog.ToolKey, toolCall.Function.Name)
+		return fmt.Sprintf(
+			"Error: unknown function %s. Call one of the available functions: %s.",
+			toolCall.Function.Name, strings.Join(a.toolNames(), ", "),
+		)
+	}
+
+	schema, ok := definition.Parameters.(jsonschema.Definition)
+	if !ok {
+		return ""
+	}
+
+	if _, err := validateArguments(schema, toolCall.Function.Arguments); err != nil {
+		a.validationFailures.Add(1)
+		metrics.ToolValidationFailures.Inc(toolCall.Function.Name)
+		a.logger().Warn("invalid tool arguments", applog.ToolKey, toolCall.Function.Name, "err", err)
+		return fmt.Sprintf(
+			"Error: invalid arguments for function %s: %v. Call the function again with arguments matching its parameters schema.",
+			toolCall.Function.Name, err,
+		)
+	}
+	return ""
+}
+
+func (a *aiclient) toolDefinition(name string) *openai.FunctionDefinition {
+	for _, t := range a.tools {
+		if t.Function != nil && t.Function.Name == name {
+			return t.Function
+		}
+	}
+	return nil
+}
+
+func (a *aiclient) toolNames() []string {
+	var names []string
+	for _, t := range a.tools {
+		if t.Function != nil {
+			names = append(names, t.Function.Name)
+		}
+	}
+	return names
+}
+
+// LastTurn returns tool calls made while answering the last user message.
+func (a *aiclient) LastTurn() TurnTrace {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.turn
+}
+
+// ValidationFailures returns how many tool calls in this session
+// were rejected because of invalid arguments.
+func (a *aiclient) ValidationFailures() int {
+	return int(a.validationFailures.Load())
+}
+
+func (a *aiclient) callFunction(ctx context.Context, toolCall openai.ToolCall) (string, error) {
 	// build-in functions
 	for _, f := range toolFunctions {
 		if f.definition.Name == toolCall.Function.Name {
-			return f.callFn(toolCall, a.sessionId)
+			return f.callFn(ctx, toolCall, a.sessionId)
 		}
 	}
 
 	// API based functions
-	for k, f := range appconfig.AppCfg.FunctionCfg {
+	for k, f := range a.appCfg.FunctionCfg {
 		if k == toolCall.Function.Name {
-			return a.callApiBasedFunction(toolCall, a.sessionId, f)
+			return a.callApiBasedFunction(ctx, toolCall, a.sessionId, f)
 		}
 	}
 	return "", fmt.Errorf("unknown function name: %s", toolCall.Function.Name)
 }
 
-func (a *aiclient) callApiBasedFunction(toolCall openai.ToolCall, sessionId string, f *appconfig.FunctionConfig) (string, error) {
+func (a *aiclient) callApiBasedFunction(ctx context.Context, toolCall openai.ToolCall, sessionId string, f *appconfig.FunctionConfig) (string, error) {
 
 	// toolCall args
 	var args map[string]interface{}
 	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	userRequest := args["request"].(string)
+	userRequest, _ := args["request"].(string)
 
 	// HTTP Request
-	log.Printf("Function request to: %s", f.Url)
+	a.logger().DebugContext(ctx, "agent request", "url", f.Url, "content", userRequest)
 	requestData := httptools.RequestData{Content: userRequest}
 	jsonData, err := json.Marshal(requestData)
 	if err != nil {
-		log.Fatal(err)
 		return "", err
 	}
 
@@ -243,45 +815,75 @@ func (a *aiclient) callApiBasedFunction(toolCall openai.ToolCall, sessionId stri
 		Timeout: 3 * time.Minute,
 	}
 
-	req, err := http.NewRequest("POST", f.Url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", f.Url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		log.Fatal(err)
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-correlationId", sessionId)
+	req.Header.Set(httptools.SessionIDHeader, sessionId)
+	req.Header.Set(httptools.TraceIDHeader, applog.TraceID(ctx))
 
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Print("client.Do ERROR")
-		// dump, _ := httputil.DumpResponse(resp, true)
-		// fmt.Printf("RAW RESPONSE:\n%s\n", dump)
-		log.Fatal(err)
+		a.logger().ErrorContext(ctx, "agent request failed", "url", f.Url, "err", err)
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Print("io.ReadAll ERROR")
-		log.Fatal(err)
+		a.logger().ErrorContext(ctx, "agent response unreadable", "url", f.Url, "err", err)
 		return "", err
 	}
 
-	log.Printf("Response from %s for function. Status: %s", f.Url, resp.Status)
-	fmt.Println("Status:", resp.Status)
-	fmt.Println("Body:", string(body))
-
 	responseBody := string(body)
-	log.Printf("Response content: %s", responseBody)
+	a.logger().DebugContext(ctx, "agent response", "url", f.Url, "status", resp.Status, "body", responseBody)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", agentError(resp.Status, body)
+	}
+
+	// structured answers of other agents are passed on as data
+	var responseData httptools.ResponseData
+	if err := json.Unmarshal(body, &responseData); err != nil {
+		return responseBody, nil
+	}
+	if responseData.Usage != nil {
+		usage.FromContext(ctx).Agent(toolCall.Function.Name, *responseData.Usage)
+	}
+	if len(responseData.Data) > 0 {
+		return string(responseData.Data), nil
+	}
 
 	return responseBody, nil
 }
 
-func (a *aiclient) GetEmbeddingOllama(model string, text string) ([]float32, error) {
+// maxAgentErrorLen keeps an error page of an agent from flooding the model context.
+const maxAgentErrorLen = 500
+
+// agentError describes a failed agent response by the error message of the
+// agent's JSON error body, or by the beginning of any other body.
+func agentError(status string, body []byte) error {
+	var errData struct {
+		Error string `json:"error"`
+	}
+	message := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &errData) == nil && errData.Error != "" {
+		message = errData.Error
+	}
+	if len(message) > maxAgentErrorLen {
+		message = message[:maxAgentErrorLen] + "..."
+	}
+	if message == "" {
+		return fmt.Errorf("agent answered %s", status)
+	}
+	return fmt.Errorf("agent answered %s: %s", status, message)
+}
+
+func (a *aiclient) GetEmbeddingOllama(ctx context.Context, model string, text string) ([]float32, error) {
 
 	resp, err := a.client.CreateEmbeddings(
-		context.Background(),
+		ctx,
 		openai.EmbeddingRequest{
 			Model: openai.EmbeddingModel(model),
 			Input: text,
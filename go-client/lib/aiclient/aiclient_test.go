@@ -0,0 +1,526 @@
+package aiclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-client/lib/appconfig"
+	"go-client/lib/applog"
+	"go-client/lib/fakellm"
+	"go-client/lib/httptools"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func newTestClient(cfg *appconfig.AiChatConfig) *aiclient {
+	a := &aiclient{
+		sessionId: "test-session",
+		appCfg:    appconfig.Current(),
+		cfg:       cfg,
+	}
+	a.defineTools()
+	return a
+}
+
+// newSession starts a session of the running config.
+func newSession(t *testing.T, sessionId string, chatName string) *aiclient {
+	t.Helper()
+	a, err := New("", sessionId, chatName)
+	if err != nil {
+		t.Fatalf("expected no error starting session, got: %v", err)
+	}
+	return a
+}
+
+func toolCall(id string, name string, arguments string) openai.ToolCall {
+	return openai.ToolCall{
+		ID:       id,
+		Type:     openai.ToolTypeFunction,
+		Function: openai.FunctionCall{Name: name, Arguments: arguments},
+	}
+}
+
+func TestRunToolCalls_KeepsOrder(t *testing.T) {
+	appconfig.Set(&appconfig.AppConfig{})
+	a := newTestClient(&appconfig.AiChatConfig{
+		AvailableFunctions: []string{"get_current_time", "get_current_weather"},
+		ToolConcurrency:    2,
+	})
+
+	results, err := a.runToolCalls(context.Background(), []openai.ToolCall{
+		toolCall("1", "get_current_weather", `{"location": "Warsaw"}`),
+		toolCall("2", "get_current_time", `{}`),
+		toolCall("3", "get_current_weather", `{}`),
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if !strings.Contains(results[0], "temperature_celsius") {
+		t.Errorf("unexpected first result: %s", results[0])
+	}
+	if !strings.HasPrefix(results[2], "Error: invalid arguments") {
+		t.Errorf("expected validation error as third result, got: %s", results[2])
+	}
+	if a.ValidationFailures() != 1 {
+		t.Errorf("expected 1 validation failure, got %d", a.ValidationFailures())
+	}
+}
+
+func TestRunToolCalls_Cancelled(t *testing.T) {
+	appconfig.Set(&appconfig.AppConfig{})
+	a := newTestClient(&appconfig.AiChatConfig{
+		AvailableFunctions: []string{"get_current_time"},
+		ToolConcurrency:    1,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := []openai.ToolCall{toolCall("1", "get_current_time", `{}`), toolCall("2", "get_current_time", `{}`)}
+	if _, err := a.runToolCalls(ctx, calls); err == nil {
+		t.Fatal("expected error for cancelled context, got nil")
+	}
+}
+
+func TestRunToolCalls_ErrorIsResult(t *testing.T) {
+	// nothing listens on the agent's port
+	appconfig.Set(&appconfig.AppConfig{
+		FunctionCfg: map[string]*appconfig.FunctionConfig{"waiter": {Url: "http://127.0.0.1:1/api/ask"}},
+	})
+	a := newTestClient(&appconfig.AiChatConfig{AvailableFunctions: []string{"get_current_time", "waiter"}})
+
+	results, err := a.runToolCalls(context.Background(), []openai.ToolCall{
+		toolCall("1", "waiter", `{"request": "something sweet"}`),
+		toolCall("2", "get_current_time", `{}`),
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !strings.HasPrefix(results[0], "Error: function waiter failed: ") {
+		t.Errorf("expected the failed call's error as its result, got: %s", results[0])
+	}
+	if strings.HasPrefix(results[1], "Error") || results[1] == "" {
+		t.Errorf("expected the other call to finish, got: %s", results[1])
+	}
+}
+
+func TestAsk_WithToolCall(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`(?i)weather`).CallTool("get_current_weather", `{"location": "Warsaw"}`).Once()
+	llm.OnRole(openai.ChatMessageRoleTool, `temperature_celsius`).Reply("It's 23.5°C and partly cloudy")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"talker": {Model: "fake", AvailableFunctions: []string{"get_current_weather"}},
+		},
+	})
+	a := newSession(t, "test-session", "talker")
+
+	answer, err := a.AskTurn(context.Background(), "What's the weather in Warsaw?")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if answer.Content != "It's 23.5°C and partly cloudy" || answer.Structured {
+		t.Errorf("unexpected answer: %+v", answer)
+	}
+
+	turn := answer.Turn
+	if turn.ToolRounds != 1 || len(turn.ToolCalls) != 1 || turn.ToolCalls[0].Name != "get_current_weather" {
+		t.Errorf("unexpected turn trace: %+v", turn)
+	}
+
+	requests := llm.Requests()
+	if len(requests) != 2 || len(requests[0].Tools) != 1 {
+		t.Fatalf("unexpected requests: %+v", requests)
+	}
+	if last := requests[1].Messages[len(requests[1].Messages)-1]; last.ToolCallID != "call_get_current_weather_1" {
+		t.Errorf("expected tool result for the tool call, got: %+v", last)
+	}
+}
+
+func TestAsk_ModelRecoversFromToolError(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`(?i)sweet`).CallTool("waiter", `{"request": "something sweet"}`).Once()
+	llm.OnRole(openai.ChatMessageRoleTool, `^Error: function waiter failed`).Reply("The waiter is away, try a Daiquiri")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg:   map[string]*appconfig.AiChatConfig{"talker": {Model: "fake", AvailableFunctions: []string{"waiter"}}},
+		FunctionCfg: map[string]*appconfig.FunctionConfig{"waiter": {Url: "http://127.0.0.1:1/api/ask"}},
+	})
+	a := newSession(t, "test-session", "talker")
+
+	answer, err := a.AskTurn(context.Background(), "Something sweet?")
+	if err != nil {
+		t.Fatalf("expected the model to answer after the tool error, got: %v", err)
+	}
+	if answer.Content != "The waiter is away, try a Daiquiri" || len(answer.Turn.ToolCalls) != 1 {
+		t.Errorf("unexpected answer: %+v", answer)
+	}
+}
+
+func TestAsk_ModelFixesInvalidArguments(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`(?i)weather`).CallTool("get_current_weather", `{}`).Once()
+	llm.OnRole(openai.ChatMessageRoleTool, `invalid arguments`).CallTool("get_current_weather", `{"location": "Warsaw"}`).Once()
+	llm.OnRole(openai.ChatMessageRoleTool, `temperature_celsius`).Reply("It's 23.5°C and partly cloudy")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"talker": {Model: "fake", AvailableFunctions: []string{"get_current_weather"}},
+		},
+	})
+	a := newSession(t, "test-session", "talker")
+
+	answer, err := a.Ask(context.Background(), "What's the weather?")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if answer != "It's 23.5°C and partly cloudy" {
+		t.Errorf("unexpected answer: %s", answer)
+	}
+	if turn := a.LastTurn(); turn.ToolRounds != 2 || len(turn.ToolCalls) != 2 {
+		t.Errorf("expected 2 tool rounds, got: %+v", turn)
+	}
+	if a.ValidationFailures() != 1 || a.LastTurn().ValidationFailures != 1 {
+		t.Errorf("expected 1 validation failure, got %d", a.ValidationFailures())
+	}
+
+	requests := llm.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 model requests, got %d", len(requests))
+	}
+	if len(requests[1].Tools) != 1 || requests[1].ToolChoice != "auto" {
+		t.Errorf("expected tools offered after tool results, got: %+v", requests[1])
+	}
+}
+
+func TestAsk_UnknownFunction(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`(?i)time`).CallTool("get_time", `{}`).Once()
+	llm.OnRole(openai.ChatMessageRoleTool, `unknown function get_time`).CallTool("get_current_time", `{}`).Once()
+	llm.OnRole(openai.ChatMessageRoleTool, ``).Reply("It's late")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"talker": {Model: "fake", AvailableFunctions: []string{"get_current_time"}},
+		},
+	})
+	a := newSession(t, "test-session", "talker")
+
+	answer, err := a.Ask(context.Background(), "What time is it?")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if answer != "It's late" {
+		t.Errorf("unexpected answer: %s", answer)
+	}
+	turn := a.LastTurn()
+	if turn.ValidationFailures != 1 || len(turn.ToolCalls) != 2 || !strings.Contains(turn.ToolCalls[0].Result, "get_current_time") {
+		t.Errorf("unexpected turn trace: %+v", turn)
+	}
+}
+
+func TestAsk_MaxToolRounds(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`(?i)time`).CallTool("get_current_time", `{}`).Once()
+	llm.OnRole(openai.ChatMessageRoleTool, ``).Reply("It's late")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"talker": {Model: "fake", AvailableFunctions: []string{"get_current_time"}, MaxToolRounds: 1},
+		},
+	})
+	a := newSession(t, "test-session", "talker")
+
+	if _, err := a.Ask(context.Background(), "What time is it?"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	requests := llm.Requests()
+	if len(requests) != 2 || len(requests[1].Tools) != 0 {
+		t.Errorf("expected tools withheld after the last round, got: %+v", requests)
+	}
+}
+
+func TestAsk_RequestPrompt(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(``).Reply("Cheers")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"talker": {Model: "fake", Prompt: appconfig.AiChatConfigPrompt{
+				Role:    appconfig.PromptSection{Text: "You serve {{.User.name}} from {{.User.city}}."},
+				Context: appconfig.PromptSection{Text: "{{.Retrieved}}"},
+			}},
+		},
+	})
+	a := newSession(t, "test-session", "talker").WithUserProfile(map[string]string{"name": "Ann", "city": "Warsaw"})
+
+	ctx := WithRequestPrompt(context.Background(), RequestPrompt{User: map[string]string{"name": "Bob"}, Retrieved: "Bob likes gin"})
+	if _, err := a.Ask(ctx, "hello"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := a.Ask(context.Background(), "hello again"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	requests := llm.Requests()
+	first, second := requests[0].Messages[0].Content, requests[1].Messages[0].Content
+	if !strings.Contains(first, "You serve Bob from Warsaw.") || !strings.Contains(first, "Bob likes gin") {
+		t.Errorf("expected request prompt data in system prompt, got:\n%s", first)
+	}
+	if !strings.Contains(second, "You serve Ann from Warsaw.") || strings.Contains(second, "gin") {
+		t.Errorf("expected request prompt data only for its turn, got:\n%s", second)
+	}
+}
+
+func TestAsk_ErrorDropsFailedTurn(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`hello`).Reply("Hi!")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{"talker": {Model: "fake"}},
+	})
+	a := newSession(t, "test-session", "talker")
+
+	if _, err := a.Ask(context.Background(), "unknown"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if _, err := a.Ask(context.Background(), "hello"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	requests := llm.Requests()
+	if n := len(requests[1].Messages); n != 2 {
+		t.Errorf("expected failed turn to be dropped from history, got %d messages", n)
+	}
+}
+
+func TestReload_KeepsSessionConfigUntilRequested(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`.`).Reply("Hi!")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"talker": {Model: "old", Prompt: appconfig.AiChatConfigPrompt{Role: appconfig.PromptSection{Text: "old role"}}},
+		},
+	})
+	a := newSession(t, "test-session", "talker")
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"talker": {
+				Model:              "new",
+				AvailableFunctions: []string{"get_current_time"},
+				Prompt:             appconfig.AiChatConfigPrompt{Role: appconfig.PromptSection{Text: "new role"}},
+			},
+		},
+	})
+	b := newSession(t, "new-session", "talker")
+
+	ctx := context.Background()
+	for _, s := range []*aiclient{a, b} {
+		if _, err := s.Ask(ctx, "hello"); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	if err := a.Reload(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := a.Ask(ctx, "hello again"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	requests := llm.Requests()
+	if requests[0].Model != "old" || requests[1].Model != "new" || requests[2].Model != "new" {
+		t.Errorf("unexpected models: %s, %s, %s", requests[0].Model, requests[1].Model, requests[2].Model)
+	}
+	if len(requests[0].Tools) != 0 || len(requests[2].Tools) != 1 {
+		t.Errorf("expected tools from reloaded config, got %d and %d", len(requests[0].Tools), len(requests[2].Tools))
+	}
+	reloaded := requests[2].Messages
+	if !strings.Contains(reloaded[0].Content, "new role") || len(reloaded) != 4 {
+		t.Errorf("expected new prompt and kept history, got: %+v", reloaded)
+	}
+
+	// a chat removed from config can't be reloaded, the session keeps its config
+	appconfig.Set(&appconfig.AppConfig{AiChatCfg: map[string]*appconfig.AiChatConfig{}})
+	if err := a.Reload(); err == nil {
+		t.Error("expected error for removed chat, got nil")
+	}
+	if a.cfg.Model != "new" {
+		t.Errorf("expected session config kept, got model %s", a.cfg.Model)
+	}
+}
+
+func TestNew_PromptParsedOncePerConfig(t *testing.T) {
+	roleFile := filepath.Join(t.TempDir(), "role.md")
+	if err := os.WriteFile(roleFile, []byte("You are {{.Chat}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"talker": {Model: "fake", Prompt: appconfig.AiChatConfigPrompt{Role: appconfig.PromptSection{File: roleFile}}},
+		},
+	})
+	newSession(t, "first", "talker")
+
+	// a prompt file broken after the config was loaded doesn't fail its sessions
+	if err := os.WriteFile(roleFile, []byte("{{.Chat"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if a := newSession(t, "second", "talker"); !strings.Contains(a.SystemPrompt(), "You are talker") {
+		t.Errorf("expected prompt of the loaded config, got %q", a.SystemPrompt())
+	}
+
+	if _, err := New("", "third", "unknown"); err == nil {
+		t.Error("expected error for unknown chat, got nil")
+	}
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"talker": {Model: "fake", Prompt: appconfig.AiChatConfigPrompt{Role: appconfig.PromptSection{File: roleFile}}},
+		},
+	})
+	if _, err := New("", "fourth", "talker"); err == nil {
+		t.Error("expected error for broken prompt file in a new config, got nil")
+	}
+}
+
+func TestAsk_UsageAndBudget(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`(?i)time`).CallTool("get_current_time", `{}`)
+	llm.OnRole(openai.ChatMessageRoleTool, `.`).Reply("It's late")
+	llm.On(`hello`).Reply("Hi!")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"talker": {Model: "fake", AvailableFunctions: []string{"get_current_time"}},
+			"capped": {Model: "fake", AvailableFunctions: []string{"get_current_time"}, Budget: appconfig.BudgetConfig{TurnTokens: 1}},
+		},
+		Pricing: map[string]appconfig.PriceConfig{"fake": {Input: 1e6, Output: 2e6}},
+	})
+	ctx := context.Background()
+
+	a := newSession(t, "test-session", "talker")
+	if _, err := a.Ask(ctx, "What time is it?"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	turn := a.LastTurn().Usage
+	model := turn.Models["fake"]
+	if model == nil || model.Calls.Count != 2 || turn.Tools.Count != 1 {
+		t.Fatalf("expected 2 model calls and 1 tool call, got %+v", turn)
+	}
+	if want := float64(turn.PromptTokens + 2*turn.CompletionTokens); turn.Cost != want {
+		t.Errorf("expected cost %g, got %g", want, turn.Cost)
+	}
+	if _, err := a.Ask(ctx, "hello"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if session := a.Usage(); session.Models["fake"].Calls.Count != 3 || session.TotalTokens <= turn.TotalTokens {
+		t.Errorf("expected session usage of both turns, got %+v", session)
+	}
+
+	// the answer to the tool result is over the turn budget
+	b := newSession(t, "capped-session", "capped")
+	_, err := b.Ask(ctx, "What time is it?")
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected budget error, got: %v", err)
+	}
+	if n := b.LastTurn().Usage.Models["fake"].Calls.Count; n != 1 {
+		t.Errorf("expected 1 model call before the budget stopped the turn, got %d", n)
+	}
+
+	// the session budget counts earlier turns
+	appconfig.Current().AiChatCfg["capped"].Budget = appconfig.BudgetConfig{SessionTokens: turn.TotalTokens}
+	c := newSession(t, "capped-session-2", "capped")
+	if _, err := c.Ask(ctx, "What time is it?"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := c.Ask(ctx, "hello"); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected budget error, got: %v", err)
+	}
+}
+
+func TestAsk_AgentCallCarriesTraceID(t *testing.T) {
+	var traceID string
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID = r.Header.Get(httptools.TraceIDHeader)
+		w.Write([]byte(`{"content": "A Negroni"}`))
+	}))
+	defer agent.Close()
+
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`bitter`).CallTool("bartender", `{"request": "something bitter"}`)
+	llm.OnRole(openai.ChatMessageRoleTool, `Negroni`).Reply("Have a Negroni")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg:   map[string]*appconfig.AiChatConfig{"talker": {Model: "fake", AvailableFunctions: []string{"bartender"}}},
+		FunctionCfg: map[string]*appconfig.FunctionConfig{"bartender": {Url: agent.URL}},
+	})
+	a := newSession(t, "test-session", "talker")
+
+	ctx := applog.WithTraceID(context.Background(), "trace-1")
+	if _, err := a.Ask(ctx, "something bitter"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if traceID != "trace-1" {
+		t.Errorf("expected trace ID passed to the agent, got %q", traceID)
+	}
+}
+
+func TestAsk_AgentErrorStatus(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGatewayTimeout)
+		w.Write([]byte(`{"error": "AI request timed out"}`))
+	}))
+	defer agent.Close()
+
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`bitter`).CallTool("bartender", `{"request": "something bitter"}`)
+	llm.OnRole(openai.ChatMessageRoleTool, `.`).Reply("The bartender is busy")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg:   map[string]*appconfig.AiChatConfig{"talker": {Model: "fake", AvailableFunctions: []string{"bartender"}}},
+		FunctionCfg: map[string]*appconfig.FunctionConfig{"bartender": {Url: agent.URL}},
+	})
+	a := newSession(t, "test-session", "talker")
+
+	answer, err := a.AskTurn(context.Background(), "something bitter")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	want := "Error: function bartender failed: agent answered 504 Gateway Timeout: AI request timed out"
+	if len(answer.Turn.ToolCalls) != 1 || answer.Turn.ToolCalls[0].Result != want {
+		t.Errorf("expected agent error as the tool result, got %+v", answer.Turn.ToolCalls)
+	}
+}
@@ -0,0 +1,196 @@
+package aiclient
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	CassetteRecord = "record"
+	CassetteReplay = "replay"
+
+	cassetteModel = "model"
+	cassetteTool  = "tool"
+)
+
+// CassetteEntry is one recorded interaction: a model request with its
+// response, or a tool call with its result. Failures are recorded too.
+type CassetteEntry struct {
+	Type     string                         `json:"type"`
+	Time     time.Time                      `json:"time"`
+	Request  *openai.ChatCompletionRequest  `json:"request,omitempty"`
+	Response *openai.ChatCompletionResponse `json:"response,omitempty"`
+	Tool     *ToolTrace                     `json:"tool,omitempty"`
+	Error    string                         `json:"error,omitempty"`
+}
+
+// Cassette records the interactions of a session to a JSONL file, or serves
+// them back from one. In replay mode neither the model nor the tools are called.
+type Cassette struct {
+	mode string
+	path string
+
+	mu        sync.Mutex
+	entries   []CassetteEntry
+	used      []bool
+	nextModel int
+}
+
+// NewRecorder creates a cassette appending interactions to the file at path.
+func NewRecorder(path string) (*Cassette, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create cassette directory: %w", err)
+	}
+	return &Cassette{mode: CassetteRecord, path: path}, nil
+}
+
+// LoadCassette reads a recorded cassette for replay.
+func LoadCassette(path string) (*Cassette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read cassette: %w", err)
+	}
+	defer f.Close()
+
+	c := &Cassette{mode: CassetteReplay, path: path}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry CassetteEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("cassette %s line %d: %w", path, line, err)
+		}
+		c.entries = append(c.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read cassette: %w", err)
+	}
+	c.used = make([]bool, len(c.entries))
+	return c, nil
+}
+
+func (c *Cassette) Path() string {
+	return c.path
+}
+
+func (c *Cassette) replaying() bool {
+	return c != nil && c.mode == CassetteReplay
+}
+
+func (c *Cassette) recording() bool {
+	return c != nil && c.mode == CassetteRecord
+}
+
+func (c *Cassette) record(entry CassetteEntry) error {
+	entry.Time = time.Now()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, err := os.OpenFile(c.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("cannot write cassette: %w", err)
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func (c *Cassette) recordModel(request openai.ChatCompletionRequest, response openai.ChatCompletionResponse, callErr error) error {
+	entry := CassetteEntry{Type: cassetteModel, Request: &request}
+	if callErr != nil {
+		entry.Error = callErr.Error()
+	} else {
+		entry.Response = &response
+	}
+	return c.record(entry)
+}
+
+func (c *Cassette) recordTool(toolCall openai.ToolCall, result string, callErr error) error {
+	entry := CassetteEntry{
+		Type: cassetteTool,
+		Tool: &ToolTrace{Name: toolCall.Function.Name, Arguments: toolCall.Function.Arguments, Result: result},
+	}
+	if callErr != nil {
+		entry.Error = callErr.Error()
+	}
+	return c.record(entry)
+}
+
+// replayModel returns the next recorded model response. The request must end
+// with the same message as the recorded one, otherwise the conversation has
+// diverged from the recording.
+func (c *Cassette) replayModel(request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := c.nextModel; i < len(c.entries); i++ {
+		entry := c.entries[i]
+		if entry.Type != cassetteModel || c.used[i] {
+			continue
+		}
+		c.used[i] = true
+		c.nextModel = i + 1
+
+		if err := sameLastMessage(entry.Request, request); err != nil {
+			return openai.ChatCompletionResponse{}, fmt.Errorf("cassette %s entry %d: %w", c.path, i+1, err)
+		}
+		if entry.Error != "" {
+			return openai.ChatCompletionResponse{}, errors.New(entry.Error)
+		}
+		if entry.Response == nil || len(entry.Response.Choices) == 0 {
+			return openai.ChatCompletionResponse{}, fmt.Errorf("cassette %s entry %d: no recorded response", c.path, i+1)
+		}
+		return *entry.Response, nil
+	}
+	return openai.ChatCompletionResponse{}, fmt.Errorf("cassette %s: no more recorded model responses", c.path)
+}
+
+// replayTool returns the recorded result of a call with the same function
+// name and arguments. Tools run concurrently, so calls aren't matched by order.
+func (c *Cassette) replayTool(toolCall openai.ToolCall) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, entry := range c.entries {
+		if entry.Type != cassetteTool || c.used[i] || entry.Tool == nil {
+			continue
+		}
+		if entry.Tool.Name != toolCall.Function.Name || entry.Tool.Arguments != toolCall.Function.Arguments {
+			continue
+		}
+		c.used[i] = true
+		if entry.Error != "" {
+			return "", errors.New(entry.Error)
+		}
+		return entry.Tool.Result, nil
+	}
+	return "", fmt.Errorf("cassette %s: no recorded call of %s(%s)", c.path, toolCall.Function.Name, toolCall.Function.Arguments)
+}
+
+func sameLastMessage(recorded *openai.ChatCompletionRequest, request openai.ChatCompletionRequest) error {
+	if recorded == nil || len(recorded.Messages) == 0 || len(request.Messages) == 0 {
+		return fmt.Errorf("no recorded request")
+	}
+	want := recorded.Messages[len(recorded.Messages)-1]
+	got := request.Messages[len(request.Messages)-1]
+	if want.Role != got.Role || want.Content != got.Content {
+		return fmt.Errorf("request diverged from recording: expected %s message %q, got %s message %q", want.Role, want.Content, got.Role, got.Content)
+	}
+	return nil
+}
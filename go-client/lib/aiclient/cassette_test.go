@@ -0,0 +1,84 @@
+package aiclient
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-client/lib/appconfig"
+	"go-client/lib/fakellm"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestCassette_RecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	llm := fakellm.New()
+	llm.On(`(?i)weather`).CallTool("get_current_weather", `{"location": "Warsaw"}`).Once()
+	llm.OnRole(openai.ChatMessageRoleTool, `temperature_celsius`).Reply("It's warm")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"talker": {Model: "fake", AvailableFunctions: []string{"get_current_weather"}},
+		},
+		Cassette: &appconfig.CassetteConfig{Mode: CassetteRecord, Dir: dir},
+	})
+
+	recorded, err := newSession(t, "rec", "talker").Ask(context.Background(), "What's the weather?")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	llm.Close()
+
+	file := filepath.Join(dir, "talker-rec.jsonl")
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("expected cassette file, got: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 3 {
+		t.Errorf("expected 2 model and 1 tool entries, got %d lines", lines)
+	}
+
+	// the model is gone, answers come from the cassette
+	appconfig.Current().Cassette = &appconfig.CassetteConfig{Mode: CassetteReplay, File: file}
+	a := newSession(t, "replay", "talker")
+	replayed, err := a.Ask(context.Background(), "What's the weather?")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if replayed != recorded {
+		t.Errorf("expected replayed answer %q, got %q", recorded, replayed)
+	}
+	if turn := a.LastTurn(); len(turn.ToolCalls) != 1 || !strings.Contains(turn.ToolCalls[0].Result, "temperature_celsius") {
+		t.Errorf("unexpected replayed turn: %+v", turn)
+	}
+
+	if _, err := a.Ask(context.Background(), "And tomorrow?"); err == nil {
+		t.Error("expected error when the cassette is exhausted, got nil")
+	}
+}
+
+func TestCassette_Diverged(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.jsonl")
+	recorder, err := NewRecorder(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := openai.ChatCompletionRequest{Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hello"}}}
+	response := openai.ChatCompletionResponse{Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "hi"}}}}
+	if err := recorder.recordModel(request, response, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cassette, err := LoadCassette(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Messages[0].Content = "goodbye"
+	if _, err := cassette.replayModel(request); err == nil || !strings.Contains(err.Error(), "diverged") {
+		t.Errorf("expected divergence error, got: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package aiclient
+
+import (
+	"context"
+	"go-client/lib/appconfig"
+	"go-client/lib/cocktail"
+)
+
+// NewEmbedder returns a function computing text vectors with the given
+// embedding model, e.g. nomic-embed-text served by Ollama, of the API in
+// the config of ctx.
+func NewEmbedder(ctx context.Context, model string) cocktail.EmbedFunc {
+	a := &aiclient{appCfg: appconfig.FromContext(ctx)}
+	a.initApiClient()
+
+	return func(ctx context.Context, text string) ([]float32, error) {
+		return a.GetEmbeddingOllama(ctx, model, text)
+	}
+}
+
+// CocktailEmbedder returns the embedder configured for the Cocktail collection
+// in the config of ctx, nil when Weaviate vectorizes the collection itself.
+func CocktailEmbedder(ctx context.Context) cocktail.EmbedFunc {
+	return CollectionEmbedder(ctx, cocktail.CocktailClassName)
+}
+
+// CollectionEmbedder returns the embedder configured for a Weaviate collection
+// in the config of ctx, nil when Weaviate vectorizes the collection itself.
+func CollectionEmbedder(ctx context.Context, className string) cocktail.EmbedFunc {
+	collection := appconfig.FromContext(ctx).Weaviate.Collection(className)
+	if collection.Vectorizer != cocktail.VectorizerNone {
+		return nil
+	}
+	return NewEmbedder(ctx, collection.EmbeddingModel)
+}
@@ -6,8 +6,6 @@ import (
 	"fmt"
 	"go-client/lib/appconfig"
 	"go-client/lib/cocktail"
-	"go-client/lib/tools"
-	"log"
 	"strings"
 	"time"
 
@@ -17,7 +15,7 @@ import (
 
 type functionDef struct {
 	definition openai.FunctionDefinition
-	callFn     func(toolCall openai.ToolCall, sessionId string) (string, error)
+	callFn     func(ctx context.Context, toolCall openai.ToolCall, sessionId string) (string, error)
 }
 
 var toolFunctions []functionDef = []functionDef{
@@ -36,7 +34,7 @@ var toolFunctions []functionDef = []functionDef{
 				Required: []string{"location"},
 			},
 		},
-		callFn: func(toolCall openai.ToolCall, sessionId string) (string, error) {
+		callFn: func(ctx context.Context, toolCall openai.ToolCall, sessionId string) (string, error) {
 			return "{\"temperature_celsius\": 23.5,\"pressure_hpa\": 1013,\"conditions\": \"Partly cloudy\",\"humidity_percent\": 65}", nil
 		},
 	},
@@ -49,7 +47,7 @@ var toolFunctions []functionDef = []functionDef{
 				Properties: map[string]jsonschema.Definition{},
 			},
 		},
-		callFn: func(toolCall openai.ToolCall, sessionId string) (string, error) {
+		callFn: func(ctx context.Context, toolCall openai.ToolCall, sessionId string) (string, error) {
 			t := time.Now()
 			return t.Format("2006-01-02 15:04:05"), nil
 		},
@@ -66,6 +64,7 @@ var toolFunctions []functionDef = []functionDef{
 						Description: "User description",
 					},
 				},
+				Required: []string{"user_description"},
 			},
 		},
 		callFn: GetCocktailList,
@@ -82,61 +81,109 @@ var toolFunctions []functionDef = []functionDef{
 						Description: "Cocktail name",
 					},
 				},
+				Required: []string{"cocktail_name"},
 			},
 		},
 		callFn: GetCocktailIstructions,
 	},
 }
 
-func GetCocktailList(toolCall openai.ToolCall, sessionId string) (string, error) {
+func init() {
+	// config validation checks availableFunctions and tools against these
+	for _, f := range toolFunctions {
+		appconfig.RegisterBuiltinFunctions(f.definition.Name)
+	}
+}
+
+func GetCocktailList(ctx context.Context, toolCall openai.ToolCall, sessionId string) (string, error) {
 
 	// Find user description
 	var args map[string]interface{}
 	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	userRequest := args["user_description"].(string)
+	userRequest, _ := args["user_description"].(string)
 
-	// Weaviate Client
-	wvc := tools.GetWeaviateClient(appconfig.AppCfg.Weaviate.Scheme, appconfig.AppCfg.Weaviate.Host)
-	ctx := context.Background()
-	cr := cocktail.NewRepository(wvc, &ctx)
+	// Cocktail store
+	cr, err := OpenCocktailStore(ctx)
+	if err != nil {
+		return "", err
+	}
 
 	// Query
-	limit := 5
-	cocktails, err := cr.GetListByNearText(userRequest, limit)
+	search := func(ctx context.Context, q cocktail.SearchQuery) ([]cocktail.SearchResult, error) {
+		defer recordStore(ctx, "search", time.Now())
+		return cr.Search(ctx, q)
+	}
+	cocktails, err := retrieveCocktails(ctx, search, toolCall.Function.Name, userRequest)
 	if err != nil {
 		return "", err
 	}
+	reranked := appconfig.FromContext(ctx).Tool(toolCall.Function.Name).Rerank.Method != ""
 
 	// Build string response
 	var builder strings.Builder
 	for _, c := range cocktails {
-		builder.WriteString(fmt.Sprintf("%s (%s)\n", c.Name, c.Ingredients))
+		if reranked {
+			builder.WriteString(fmt.Sprintf("%s (%s) [relevance %.1f/10]\n", c.Name, c.Ingredients, c.Relevance))
+		} else {
+			builder.WriteString(fmt.Sprintf("%s (%s)\n", c.Name, c.Ingredients))
+		}
 	}
 
 	return builder.String(), nil
 }
 
-func GetCocktailIstructions(toolCall openai.ToolCall, sessionId string) (string, error) {
+func GetCocktailIstructions(ctx context.Context, toolCall openai.ToolCall, sessionId string) (string, error) {
 
 	// Find user description
 	var args map[string]interface{}
 	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	cocktailName := args["cocktail_name"].(string)
+	cocktailName, _ := args["cocktail_name"].(string)
 
-	// Weaviate Client
-	wvc := tools.GetWeaviateClient(appconfig.AppCfg.Weaviate.Scheme, appconfig.AppCfg.Weaviate.Host)
-	ctx := context.Background()
-	cr := cocktail.NewRepository(wvc, &ctx)
+	// Cocktail store
+	cr, err := OpenCocktailStore(ctx)
+	if err != nil {
+		return "", err
+	}
 
 	// Query
-	cocktail, err := cr.GetByCocktailName(cocktailName)
+	start := time.Now()
+	cocktail, err := cr.GetByCocktailName(ctx, cocktailName)
+	recordStore(ctx, "get", start)
 	if err != nil {
 		return "", err
 	}
 
 	return fmt.Sprintf("Name: %s\nIngredients: %s\nPreparation: %s\n", cocktail.Name, cocktail.Ingredients, cocktail.Preparation), nil
 }
+
+// toolSearchQuery builds a cocktail search for a tool from its "search" config.
+// Without config it falls back to near-text search, 5 results, distance 0.6.
+func toolSearchQuery(ctx context.Context, toolName string, text string) (cocktail.SearchQuery, error) {
+	cfg := appconfig.FromContext(ctx).Tool(toolName).Search
+
+	query := cocktail.SearchQuery{
+		Text:     text,
+		Mode:     cocktail.SearchNearText,
+		Limit:    5,
+		Distance: 0.6,
+		Alpha:    cfg.Alpha,
+	}
+	if cfg.Mode != "" {
+		mode, err := cocktail.ParseSearchMode(cfg.Mode)
+		if err != nil {
+			return cocktail.SearchQuery{}, err
+		}
+		query.Mode = mode
+	}
+	if cfg.Limit > 0 {
+		query.Limit = cfg.Limit
+	}
+	if cfg.Distance > 0 {
+		query.Distance = cfg.Distance
+	}
+	return query, nil
+}
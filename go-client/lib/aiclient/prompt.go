@@ -11,6 +11,12 @@ type prompt struct {
 	examples     string
 	task         string
 	instructions string
+	sections     []promptSection
+}
+
+type promptSection struct {
+	name string
+	text string
 }
 
 func PromptBuilder() *prompt {
@@ -44,6 +50,12 @@ func (p *prompt) WithInstructions(instructions string) *prompt {
 	return p
 }
 
+// WithSection adds a custom section, custom sections follow the fixed ones.
+func (p *prompt) WithSection(name string, text string) *prompt {
+	p.sections = append(p.sections, promptSection{name: strings.ToUpper(name), text: text})
+	return p
+}
+
 func (p *prompt) Get() string {
 	var builder strings.Builder
 	if p.role != "" {
@@ -61,5 +73,10 @@ func (p *prompt) Get() string {
 	if p.instructions != "" {
 		builder.WriteString(fmt.Sprintf("[INSTRUCTIONS]\n%s\n\n", p.instructions))
 	}
+	for _, s := range p.sections {
+		if s.text != "" {
+			builder.WriteString(fmt.Sprintf("[%s]\n%s\n\n", s.name, s.text))
+		}
+	}
 	return builder.String()
 }
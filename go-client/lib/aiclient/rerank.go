@@ -0,0 +1,199 @@
+package aiclient
+
+import (
+	"context"
+	"fmt"
+	"go-client/lib/appconfig"
+	"go-client/lib/cocktail"
+	"go-client/lib/metrics"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	RerankLLM     = "llm"
+	RerankKeyword = "keyword"
+
+	rerankConcurrency = 4
+)
+
+// rankedCocktail is a retrieved cocktail with its relevance to the user
+// description, 0 - not relevant, 10 - perfect match.
+type rankedCocktail struct {
+	cocktail.SearchResult
+	Relevance float64
+}
+
+// rerankCandidates returns how many cocktails a tool fetches before re-ranking,
+// the configured number or 3 times limit.
+func rerankCandidates(cfg appconfig.RerankConfig, limit int) int {
+	if cfg.Method == "" {
+		return limit
+	}
+	if cfg.Candidates > 0 {
+		return cfg.Candidates
+	}
+	return limit * 3
+}
+
+// rerankCocktails scores candidates against the user description and returns the best limit of them.
+func rerankCocktails(ctx context.Context, cfg appconfig.RerankConfig, description string, candidates []cocktail.SearchResult, limit int) ([]rankedCocktail, error) {
+	var scores []float64
+	var err error
+
+	switch cfg.Method {
+	case RerankLLM:
+		scores, err = llmScores(ctx, cfg.Model, description, candidates)
+	case RerankKeyword:
+		scores = keywordScores(description, candidates)
+	default:
+		return nil, fmt.Errorf("unknown rerank method %q", cfg.Method)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := make([]rankedCocktail, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = rankedCocktail{SearchResult: c, Relevance: scores[i]}
+	}
+	// stable, so retrieval order decides between equal scores
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Relevance > ranked[j].Relevance
+	})
+
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked, nil
+}
+
+const rerankPrompt = `Rate how well the cocktail matches the guest's wishes.
+Answer with a single number from 0 (does not match) to 10 (perfect match). Do not explain.
+
+Guest's wishes: %s
+
+Cocktail: %s
+Ingredients: %s`
+
+var scoreNumber = regexp.MustCompile(`\d+(\.\d+)?`)
+
+// llmScores asks the model to rate every (description, cocktail) pair separately,
+// like a cross-encoder does.
+func llmScores(ctx context.Context, model string, description string, candidates []cocktail.SearchResult) ([]float64, error) {
+	if model == "" {
+		return nil, fmt.Errorf("rerank model is not configured")
+	}
+
+	a := &aiclient{appCfg: appconfig.FromContext(ctx)}
+	a.initApiClient()
+
+	scores := make([]float64, len(candidates))
+	errs := make([]error, len(candidates))
+	sem := make(chan struct{}, rerankConcurrency)
+
+	var wg sync.WaitGroup
+	for i, c := range candidates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			response, err := a.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+				Model:       model,
+				Temperature: 0,
+				Messages: []openai.ChatCompletionMessage{
+					{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf(rerankPrompt, description, c.Name, c.Ingredients)},
+				},
+			})
+			metrics.ObserveModel(model, response.Usage.PromptTokens, response.Usage.CompletionTokens, time.Since(start), err)
+			a.recordModel(ctx, model, response.Usage, time.Since(start))
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			if len(response.Choices) == 0 {
+				errs[i] = fmt.Errorf("no rerank response for %s", c.Name)
+				return
+			}
+			scores[i] = parseScore(response.Choices[0].Message.Content)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return scores, nil
+}
+
+// parseScore reads the rating from a model answer, unreadable answers score 0.
+func parseScore(answer string) float64 {
+	answer = StripThink(answer)
+	number := scoreNumber.FindString(answer)
+	score, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		logger().Warn("cannot read rerank score", "answer", answer)
+		return 0
+	}
+	return min(score, 10)
+}
+
+// keywordScores is a local heuristic: the share of description words
+// found as whole words in cocktail name and ingredients, scaled to 0-10.
+func keywordScores(description string, candidates []cocktail.SearchResult) []float64 {
+	keywords := keywords(description)
+	scores := make([]float64, len(candidates))
+	if len(keywords) == 0 {
+		return scores
+	}
+
+	for i, c := range candidates {
+		text := map[string]bool{}
+		for _, w := range words(c.Name + " " + c.Ingredients) {
+			text[w] = true
+		}
+		matched := 0
+		for _, w := range keywords {
+			if text[w] {
+				matched++
+			}
+		}
+		scores[i] = 10 * float64(matched) / float64(len(keywords))
+	}
+	return scores
+}
+
+// keywords returns words of text long enough to tell something.
+func keywords(text string) []string {
+	keywords := []string{}
+	for _, w := range words(text) {
+		if len(w) >= 3 {
+			keywords = append(keywords, w)
+		}
+	}
+	return keywords
+}
+
+// words splits text into lower case words.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
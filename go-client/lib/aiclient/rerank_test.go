@@ -0,0 +1,81 @@
+package aiclient
+
+import (
+	"context"
+	"errors"
+	"go-client/lib/appconfig"
+	"go-client/lib/cocktail"
+	"testing"
+)
+
+func TestParseScore(t *testing.T) {
+	tests := map[string]float64{
+		"7":                          7,
+		"<think>maybe 2?</think>8.5": 8.5,
+		"Score: 12":                  10,
+		"no idea":                    0,
+	}
+	for answer, expected := range tests {
+		if score := parseScore(answer); score != expected {
+			t.Errorf("%q: expected %v, got %v", answer, expected, score)
+		}
+	}
+}
+
+func TestRerankCocktails_Keyword(t *testing.T) {
+	candidates := []cocktail.SearchResult{
+		{Cocktail: cocktail.Cocktail{Name: "Negroni", Ingredients: "gin, campari, vermouth"}},
+		{Cocktail: cocktail.Cocktail{Name: "Mezcal Mule", Ingredients: "mezcal, ginger beer, lime"}},
+		{Cocktail: cocktail.Cocktail{Name: "Daiquiri", Ingredients: "rum, lime, sugar"}},
+	}
+
+	ranked, err := rerankCocktails(context.Background(), appconfig.RerankConfig{Method: RerankKeyword}, "mezcal with lime", candidates, 2)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(ranked) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(ranked))
+	}
+	if ranked[0].Name != "Mezcal Mule" || ranked[1].Name != "Daiquiri" {
+		t.Errorf("unexpected order: %s, %s", ranked[0].Name, ranked[1].Name)
+	}
+}
+
+func TestKeywordScores_WholeWords(t *testing.T) {
+	candidates := []cocktail.SearchResult{
+		{Cocktail: cocktail.Cocktail{Name: "Mezcal Mule", Ingredients: "mezcal, ginger beer, lime"}},
+		{Cocktail: cocktail.Cocktail{Name: "Gimlet", Ingredients: "gin, lime"}},
+	}
+	scores := keywordScores("gin please", candidates)
+	if scores[0] != 0 || scores[1] != 5 {
+		t.Errorf("expected only whole word gin to match, got %v", scores)
+	}
+}
+
+func TestRerankCandidates(t *testing.T) {
+	tests := []struct {
+		cfg      appconfig.RerankConfig
+		expected int
+	}{
+		{appconfig.RerankConfig{}, 5},
+		{appconfig.RerankConfig{Method: RerankKeyword}, 15},
+		{appconfig.RerankConfig{Method: RerankKeyword, Candidates: 5}, 5},
+		{appconfig.RerankConfig{Method: RerankKeyword, Candidates: 40}, 40},
+	}
+	for _, tt := range tests {
+		if got := rerankCandidates(tt.cfg, 5); got != tt.expected {
+			t.Errorf("%+v: expected %d, got %d", tt.cfg, tt.expected, got)
+		}
+	}
+}
+
+func TestLLMScores_Cancelled(t *testing.T) {
+	appconfig.Set(&appconfig.AppConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	candidates := make([]cocktail.SearchResult, 2*rerankConcurrency)
+	if _, err := llmScores(ctx, "fake", "smoky", candidates); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package aiclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-client/lib/appconfig"
+	"go-client/lib/cocktail"
+	"go-client/lib/metrics"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultRewriteQueries = 3
+	rrfK                  = 60
+)
+
+type searchFunc func(ctx context.Context, q cocktail.SearchQuery) ([]cocktail.SearchResult, error)
+
+// rewrittenRequest is a user request split by the model into focused
+// search queries plus wanted and unwanted ingredients.
+type rewrittenRequest struct {
+	Queries []string `json:"queries"`
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+// retrieveCocktails runs the retrieval pipeline of a tool: optional query
+// rewriting with multi-query search merged by reciprocal-rank fusion, then
+// optional re-ranking. Relevance is set only when re-ranking is enabled.
+func retrieveCocktails(ctx context.Context, search searchFunc, toolName string, description string) ([]rankedCocktail, error) {
+	toolCfg := appconfig.FromContext(ctx).Tool(toolName)
+
+	query, err := toolSearchQuery(ctx, toolName, description)
+	if err != nil {
+		return nil, err
+	}
+	limit := query.Limit
+	query.Limit = rerankCandidates(toolCfg.Rerank, limit)
+
+	var candidates []cocktail.SearchResult
+	if toolCfg.Rewrite.Model == "" {
+		candidates, err = search(ctx, query)
+	} else {
+		candidates, err = multiQuerySearch(ctx, search, toolCfg.Rewrite, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if toolCfg.Rerank.Method != "" {
+		return rerankCocktails(ctx, toolCfg.Rerank, description, candidates, limit)
+	}
+
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	ranked := make([]rankedCocktail, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = rankedCocktail{SearchResult: c}
+	}
+	return ranked, nil
+}
+
+// multiQuerySearch runs every rewritten query with exclusions as filters
+// and merges the result lists by reciprocal-rank fusion.
+func multiQuerySearch(ctx context.Context, search searchFunc, cfg appconfig.RewriteConfig, base cocktail.SearchQuery) ([]cocktail.SearchResult, error) {
+	request, err := rewriteRequest(ctx, cfg, base.Text)
+	if err != nil {
+		// retrieval still works without rewriting, only worse
+		logger().WarnContext(ctx, "query rewriting failed, searching for original request", "err", err)
+		return search(ctx, base)
+	}
+	logger().DebugContext(ctx, "rewritten request", "queries", request.Queries, "include", request.Include, "exclude", request.Exclude)
+
+	filters := append([]cocktail.Filter{}, base.Filters...)
+	for _, e := range request.Exclude {
+		filters = append(filters, cocktail.Filter{Property: "ingredients", Operator: cocktail.FilterNotEqual, Value: e})
+	}
+
+	queries := request.Queries
+	if len(request.Include) > 0 {
+		queries = append(queries, strings.Join(request.Include, " "))
+	}
+
+	lists := make([][]cocktail.SearchResult, len(queries))
+	errs := make([]error, len(queries))
+	var wg sync.WaitGroup
+	for i, text := range queries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q := base
+			q.Text = text
+			q.Filters = filters
+			lists[i], errs[i] = search(ctx, q)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	merged := reciprocalRankFusion(lists)
+	if base.Limit > 0 && len(merged) > base.Limit {
+		merged = merged[:base.Limit]
+	}
+	return merged, nil
+}
+
+const rewritePrompt = `You prepare search queries for a cocktail database.
+Rewrite the guest's request into at most %d short, focused search queries (flavours, style, occasion, spirits).
+List ingredients the guest wants in "include" and ingredients the guest does not want in "exclude".
+Answer only with JSON: {"queries": ["..."], "include": ["..."], "exclude": ["..."]}
+
+Guest's request: %s`
+
+func rewriteRequest(ctx context.Context, cfg appconfig.RewriteConfig, description string) (rewrittenRequest, error) {
+	maxQueries := cfg.Queries
+	if maxQueries <= 0 {
+		maxQueries = defaultRewriteQueries
+	}
+
+	a := &aiclient{appCfg: appconfig.FromContext(ctx)}
+	a.initApiClient()
+
+	start := time.Now()
+	response, err := a.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:       cfg.Model,
+		Temperature: 0,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf(rewritePrompt, maxQueries, description)},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+	})
+	metrics.ObserveModel(cfg.Model, response.Usage.PromptTokens, response.Usage.CompletionTokens, time.Since(start), err)
+	a.recordModel(ctx, cfg.Model, response.Usage, time.Since(start))
+	if err != nil {
+		return rewrittenRequest{}, err
+	}
+	if len(response.Choices) == 0 {
+		return rewrittenRequest{}, fmt.Errorf("no rewrite response")
+	}
+
+	return parseRewrittenRequest(response.Choices[0].Message.Content, maxQueries)
+}
+
+func parseRewrittenRequest(answer string, maxQueries int) (rewrittenRequest, error) {
+	answer = StripThink(answer)
+
+	var request rewrittenRequest
+	if err := json.Unmarshal([]byte(answer), &request); err != nil {
+		return rewrittenRequest{}, fmt.Errorf("invalid rewrite JSON: %w", err)
+	}
+
+	request.Queries = nonEmpty(request.Queries)
+	request.Include = nonEmpty(request.Include)
+	request.Exclude = nonEmpty(request.Exclude)
+	if len(request.Queries) == 0 {
+		return rewrittenRequest{}, fmt.Errorf("no queries in rewrite response")
+	}
+	if len(request.Queries) > maxQueries {
+		request.Queries = request.Queries[:maxQueries]
+	}
+	return request, nil
+}
+
+func nonEmpty(values []string) []string {
+	result := []string{}
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// reciprocalRankFusion merges ranked lists, a cocktail scores 1/(k+rank)
+// for every list it appears in. Cocktails are identified by name.
+func reciprocalRankFusion(lists [][]cocktail.SearchResult) []cocktail.SearchResult {
+	scores := map[string]float64{}
+	first := map[string]cocktail.SearchResult{}
+	order := []string{}
+
+	for _, list := range lists {
+		for rank, c := range list {
+			if _, ok := first[c.Name]; !ok {
+				first[c.Name] = c
+				order = append(order, c.Name)
+			}
+			scores[c.Name] += 1 / float64(rrfK+rank+1)
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+
+	merged := make([]cocktail.SearchResult, len(order))
+	for i, name := range order {
+		merged[i] = first[name]
+	}
+	return merged
+}
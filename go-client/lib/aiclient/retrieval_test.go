@@ -0,0 +1,49 @@
+package aiclient
+
+import (
+	"go-client/lib/cocktail"
+	"testing"
+)
+
+func TestParseRewrittenRequest(t *testing.T) {
+	answer := `<think>hot day</think>{"queries": ["refreshing light", " ", "citrus", "low alcohol"], "include": [], "exclude": ["gin"]}`
+
+	request, err := parseRewrittenRequest(answer, 2)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(request.Queries) != 2 || request.Queries[1] != "citrus" {
+		t.Errorf("unexpected queries: %q", request.Queries)
+	}
+	if len(request.Exclude) != 1 || request.Exclude[0] != "gin" {
+		t.Errorf("unexpected exclude: %q", request.Exclude)
+	}
+
+	if _, err := parseRewrittenRequest(`{"queries": []}`, 2); err == nil {
+		t.Error("expected error for empty queries, got nil")
+	}
+}
+
+func TestReciprocalRankFusion(t *testing.T) {
+	result := func(names ...string) []cocktail.SearchResult {
+		list := []cocktail.SearchResult{}
+		for _, n := range names {
+			list = append(list, cocktail.SearchResult{Cocktail: cocktail.Cocktail{Name: n}})
+		}
+		return list
+	}
+
+	merged := reciprocalRankFusion([][]cocktail.SearchResult{
+		result("A", "B", "C"),
+		result("B", "D"),
+		result("C", "B"),
+	})
+
+	names := []string{}
+	for _, c := range merged {
+		names = append(names, c.Name)
+	}
+	if len(names) != 4 || names[0] != "B" {
+		t.Errorf("unexpected fusion order: %v", names)
+	}
+}
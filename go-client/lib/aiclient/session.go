@@ -0,0 +1,101 @@
+package aiclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-client/lib/metrics"
+	"go-client/lib/usage"
+	"os"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Reset starts the conversation again, only the system prompt is kept.
+func (a *aiclient) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.messages = nil
+	a.turn = TurnTrace{}
+	if err := a.renderSystemPrompt(); err != nil {
+		a.logger().Error("prompt rendering failed", "err", err)
+	}
+}
+
+// Close ends the session, it's no longer counted as active.
+func (a *aiclient) Close() {
+	if a.closed.CompareAndSwap(false, true) {
+		metrics.ActiveSessions.Dec(a.chatName)
+	}
+}
+
+// Tools returns tools available to the model.
+func (a *aiclient) Tools() []PromptTool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.promptData().Tools
+}
+
+// Model returns the model answering in this session.
+func (a *aiclient) Model() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.cfg.Model
+}
+
+// SetModel changes the model of this session only, until Reload.
+func (a *aiclient) SetModel(model string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// the chat config is shared with other sessions
+	cfg := *a.cfg
+	cfg.Model = model
+	a.cfg = &cfg
+}
+
+// sessionExport is a conversation saved by SaveHistory.
+type sessionExport struct {
+	Session  string                         `json:"session"`
+	Chat     string                         `json:"chat"`
+	Messages []openai.ChatCompletionMessage `json:"messages"`
+	Usage    usage.Usage                    `json:"usage"`
+}
+
+// SaveHistory writes the conversation with the session's usage to a JSON file.
+func (a *aiclient) SaveHistory(path string) error {
+	a.mu.Lock()
+	export := sessionExport{Session: a.sessionId, Chat: a.chatName, Messages: a.messages, Usage: a.Usage()}
+	data, err := json.MarshalIndent(export, "", "  ")
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// LoadHistory replaces the conversation with one saved by SaveHistory, or a
+// JSON array of messages. The system prompt and usage of the session are kept.
+func (a *aiclient) LoadHistory(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var messages []openai.ChatCompletionMessage
+	if err := json.Unmarshal(data, &messages); err != nil {
+		var export sessionExport
+		if err := json.Unmarshal(data, &export); err != nil {
+			return fmt.Errorf("invalid history file %s: %w", path, err)
+		}
+		messages = export.Messages
+	}
+	if len(messages) > 0 && messages[0].Role == openai.ChatMessageRoleSystem {
+		messages = messages[1:]
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.messages = messages
+	a.turn = TurnTrace{}
+	return a.renderSystemPrompt()
+}
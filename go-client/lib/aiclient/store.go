@@ -0,0 +1,136 @@
+package aiclient
+
+import (
+	"context"
+	"fmt"
+	"go-client/lib/appconfig"
+	"go-client/lib/cocktail"
+	"go-client/lib/examples"
+	"go-client/lib/tools"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	StoreWeaviate = "weaviate"
+	StoreMemory   = "memory"
+)
+
+// the memory store lives as long as the process, it is loaded again
+// only when the store config changes
+var (
+	memoryMu   sync.Mutex
+	memoryLoad *memoryStoreLoad
+)
+
+// memoryStoreLoad is loading of the memory store shared by all callers,
+// done is closed when store or err is set. A failed load is kept as well,
+// it is retried only with another config.
+type memoryStoreLoad struct {
+	cfg   appconfig.StoreConfig
+	done  chan struct{}
+	store cocktail.CocktailStore
+	err   error
+}
+
+// OpenCocktailStore returns the cocktail store selected by the "store" config,
+// Weaviate by default.
+func OpenCocktailStore(ctx context.Context) (cocktail.CocktailStore, error) {
+	appCfg := appconfig.FromContext(ctx)
+	cfg := appCfg.Store
+	if cfg == nil || cfg.Type == "" || cfg.Type == StoreWeaviate {
+		wvc, err := tools.GetWeaviateClient(ctx, appCfg.Weaviate.Scheme, appCfg.Weaviate.Host)
+		if err != nil {
+			// the Weaviate client doesn't wrap errors, a cancelled request is
+			// told by ctx, so callers can tell it from Weaviate being down
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		collection := appCfg.Weaviate.Collection(cocktail.CocktailClassName)
+		return cocktail.NewRepository(wvc, collection.Vectorizer, CocktailEmbedder(ctx)), nil
+	}
+	if cfg.Type != StoreMemory {
+		return nil, fmt.Errorf("unknown store type %q, expected %s or %s", cfg.Type, StoreWeaviate, StoreMemory)
+	}
+
+	memoryMu.Lock()
+	load := memoryLoad
+	if load == nil || load.cfg != *cfg {
+		load = &memoryStoreLoad{cfg: *cfg, done: make(chan struct{})}
+		memoryLoad = load
+		// the load outlives the request starting it, other requests wait for it
+		go load.run(context.WithoutCancel(ctx))
+	}
+	memoryMu.Unlock()
+
+	select {
+	case <-load.done:
+		return load.store, load.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *memoryStoreLoad) run(ctx context.Context) {
+	defer close(l.done)
+	l.store, l.err = loadMemoryStore(ctx, l.cfg)
+	if l.err != nil {
+		logger().ErrorContext(ctx, "memory store loading failed", "file", l.cfg.Data, "err", l.err)
+	}
+}
+
+func loadMemoryStore(ctx context.Context, cfg appconfig.StoreConfig) (cocktail.CocktailStore, error) {
+	var embed cocktail.EmbedFunc
+	if cfg.EmbeddingModel != "" {
+		embed = NewEmbedder(ctx, cfg.EmbeddingModel)
+	}
+	store := cocktail.NewMemoryStore(embed)
+
+	if cfg.Data != "" {
+		cocktails, err := cocktail.LoadCSV(cfg.Data)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cocktails {
+			if err := store.Save(ctx, c); err != nil {
+				return nil, err
+			}
+		}
+		logger().InfoContext(ctx, "memory store loaded", "cocktails", len(cocktails), "file", cfg.Data)
+	}
+	return store, nil
+}
+
+// OpenExampleStore returns the few-shot example store of a chat: its own Weaviate
+// collection, or a JSONL file in memory store mode.
+func OpenExampleStore(ctx context.Context, chat string) (examples.ExampleStore, error) {
+	appCfg := appconfig.FromContext(ctx)
+	cfg := appCfg.Store
+	if cfg == nil || cfg.Type == "" || cfg.Type == StoreWeaviate {
+		wvc, err := tools.GetWeaviateClient(ctx, appCfg.Weaviate.Scheme, appCfg.Weaviate.Host)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		className := examples.ClassName(chat)
+		collection := appCfg.Weaviate.Collection(className)
+		return examples.NewWeaviateStore(wvc, chat, collection.Vectorizer, CollectionEmbedder(ctx, className)), nil
+	}
+	if cfg.Type != StoreMemory {
+		return nil, fmt.Errorf("unknown store type %q, expected %s or %s", cfg.Type, StoreWeaviate, StoreMemory)
+	}
+
+	dir := cfg.ExamplesDir
+	if dir == "" {
+		dir = "data/examples"
+	}
+	var embed cocktail.EmbedFunc
+	if cfg.EmbeddingModel != "" {
+		embed = NewEmbedder(ctx, cfg.EmbeddingModel)
+	}
+	return examples.NewFileStore(filepath.Join(dir, chat+".jsonl"), embed), nil
+}
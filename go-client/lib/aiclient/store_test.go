@@ -0,0 +1,184 @@
+package aiclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-client/lib/appconfig"
+	"go-client/lib/cocktail"
+	"go-client/lib/fakellm"
+)
+
+const testCocktailsCSV = `Cocktail Name,Ingredients,Preparation
+Negroni,"1 oz Gin, 1 oz Campari, 1 oz Sweet Vermouth",Stir
+Mezcal Negroni,"1 oz Mezcal, 1 oz Campari, 1 oz Sweet Vermouth",Stir
+Daiquiri,"2 oz Rum, 1 oz Lime Juice, .75 oz Simple Syrup",Shake
+`
+
+func TestCocktailTools_MemoryStore(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	data := filepath.Join(t.TempDir(), "cocktails.csv")
+	if err := os.WriteFile(data, []byte(testCocktailsCSV), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	appconfig.Set(&appconfig.AppConfig{
+		Store: &appconfig.StoreConfig{Type: StoreMemory, Data: data, EmbeddingModel: "fake"},
+		ToolCfg: map[string]*appconfig.ToolConfig{
+			"cocktail_list": {Search: appconfig.SearchConfig{Mode: "bm25", Limit: 2}},
+		},
+	})
+	ctx := context.Background()
+
+	list, err := GetCocktailList(ctx, toolCall("1", "cocktail_list", `{"user_description": "something with campari"}`), "test-session")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !strings.Contains(list, "Negroni (") || strings.Contains(list, "Daiquiri") {
+		t.Errorf("unexpected cocktail list: %s", list)
+	}
+
+	recipe, err := GetCocktailIstructions(ctx, toolCall("2", "cocktail_recipe", `{"cocktail_name": "Daiquiri"}`), "test-session")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !strings.Contains(recipe, "Preparation: Shake") {
+		t.Errorf("unexpected recipe: %s", recipe)
+	}
+
+	// near-text search embeds the query with the fake model
+	appconfig.Current().ToolCfg["cocktail_list"].Search = appconfig.SearchConfig{Mode: "near-text", Limit: 1, Distance: 0.9}
+	list, err = GetCocktailList(ctx, toolCall("3", "cocktail_list", `{"user_description": "mezcal"}`), "test-session")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !strings.HasPrefix(list, "Mezcal Negroni") {
+		t.Errorf("unexpected cocktail list: %s", list)
+	}
+}
+
+func TestOpenCocktailStore_MemoryLoadedOnce(t *testing.T) {
+	data := filepath.Join(t.TempDir(), "cocktails.csv")
+	appconfig.Set(&appconfig.AppConfig{Store: &appconfig.StoreConfig{Type: StoreMemory, Data: data}})
+
+	// a failed load is kept until the config changes
+	if _, err := OpenCocktailStore(context.Background()); err == nil {
+		t.Fatal("expected error for missing data file, got nil")
+	}
+	if err := os.WriteFile(data, []byte(testCocktailsCSV), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenCocktailStore(context.Background()); err == nil {
+		t.Error("expected the failed load to be kept")
+	}
+
+	appconfig.Set(&appconfig.AppConfig{Store: &appconfig.StoreConfig{Type: StoreMemory, Data: data, ExamplesDir: "other"}})
+
+	// a cancelled caller doesn't cancel the load for others
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	OpenCocktailStore(cancelled)
+
+	stores := make(chan cocktail.CocktailStore, 3)
+	for range 3 {
+		go func() {
+			store, err := OpenCocktailStore(context.Background())
+			if err != nil {
+				t.Errorf("expected no error, got: %v", err)
+			}
+			stores <- store
+		}()
+	}
+	first := <-stores
+	if first == nil || <-stores != first || <-stores != first {
+		t.Error("expected one store shared by all callers")
+	}
+}
+
+func TestOpenCocktailStore_WeaviateCancelled(t *testing.T) {
+	weaviate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer weaviate.Close()
+	cfg := &appconfig.AppConfig{Weaviate: &appconfig.WeaviateConfig{Scheme: "http", Host: strings.TrimPrefix(weaviate.URL, "http://")}}
+
+	ctx, cancel := context.WithCancel(appconfig.WithConfig(context.Background(), cfg))
+	cancel()
+	if _, err := OpenCocktailStore(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation error, got: %v", err)
+	}
+	if _, err := OpenExampleStore(ctx, "waiter"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation error, got: %v", err)
+	}
+}
+
+func TestCocktailEmbedder_ContextConfig(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+
+	appconfig.Set(&appconfig.AppConfig{OpenAI: &appconfig.OpenAIConfig{URL: "http://127.0.0.1:1/v1"}})
+	sessionCfg := &appconfig.AppConfig{
+		OpenAI: &appconfig.OpenAIConfig{URL: llm.URL()},
+		Weaviate: &appconfig.WeaviateConfig{Collections: map[string]*appconfig.CollectionConfig{
+			"Cocktail": {Vectorizer: "none", EmbeddingModel: "fake"},
+		}},
+	}
+	ctx := appconfig.WithConfig(context.Background(), sessionCfg)
+
+	if CocktailEmbedder(context.Background()) != nil {
+		t.Error("expected no embedder for the running config")
+	}
+	embed := CocktailEmbedder(ctx)
+	if embed == nil {
+		t.Fatal("expected an embedder for the session config")
+	}
+	if vector, err := embed(ctx, "negroni"); err != nil || len(vector) != fakellm.EmbeddingSize {
+		t.Errorf("expected embedding from the session API, got %d values, error: %v", len(vector), err)
+	}
+}
+
+func TestAsk_SelectsExamples(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`.*`).Reply("Mezcal Negroni")
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "waiter.jsonl"), []byte(
+		`{"input": "something with mezcal", "output": "- Mezcal Negroni"}`+"\n"+
+			`{"input": "a refreshing drink for summer", "output": "- Mojito"}`+"\n"), 0o644)
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"waiter": {
+				Model:    "fake",
+				Prompt:   appconfig.AiChatConfigPrompt{Examples: appconfig.PromptSection{Text: "User: hi\nAssistant: hello"}},
+				Examples: appconfig.ExamplesConfig{K: 1},
+			},
+		},
+		Store: &appconfig.StoreConfig{Type: StoreMemory, EmbeddingModel: "fake", ExamplesDir: dir},
+	})
+	a := newSession(t, "test-session", "waiter")
+
+	for _, input := range []string{"anything with mezcal?", "a refreshing summer drink"} {
+		if _, err := a.Ask(context.Background(), input); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	requests := llm.Requests()
+	first := requests[0].Messages[0].Content
+	second := requests[1].Messages[0].Content
+	if !strings.Contains(first, "[EXAMPLES]\nUser: hi\nAssistant: hello\n\nUser: something with mezcal\nAssistant: - Mezcal Negroni") {
+		t.Errorf("expected mezcal example after configured one, got:\n%s", first)
+	}
+	if !strings.Contains(second, "Mojito") || strings.Contains(second, "Mezcal Negroni") {
+		t.Errorf("expected examples selected for the second turn only, got:\n%s", second)
+	}
+}
@@ -0,0 +1,105 @@
+package aiclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// EventType tells what happened while answering.
+type EventType string
+
+const (
+	EventContent    EventType = "content"     // Text is the next part of a model answer
+	EventToolCall   EventType = "tool_call"   // Tool is called with Text arguments
+	EventToolResult EventType = "tool_result" // Tool returned Text
+)
+
+// Event is a step of a turn, sent to the function set by WithStream.
+type Event struct {
+	Type EventType
+	Tool string
+	Text string
+}
+
+// WithStream makes the model stream answers, fn gets content as it is
+// generated, tool calls and their results. Events are sent from the
+// goroutine calling Ask, one at a time.
+func (a *aiclient) WithStream(fn func(Event)) *aiclient {
+	a.stream = fn
+	return a
+}
+
+func (a *aiclient) emit(e Event) {
+	if a.stream != nil {
+		a.stream(e)
+	}
+}
+
+// streamChatCompletion calls the model in streaming mode and assembles
+// chunks into a response, as returned without streaming.
+func (a *aiclient) streamChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	request.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	stream, err := a.client.CreateChatCompletionStream(ctx, request)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	defer stream.Close()
+
+	response := openai.ChatCompletionResponse{Object: "chat.completion"}
+	msg := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant}
+	var content strings.Builder
+	var finishReason openai.FinishReason
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return openai.ChatCompletionResponse{}, err
+		}
+
+		response.ID, response.Created, response.Model = chunk.ID, chunk.Created, chunk.Model
+		if chunk.Usage != nil {
+			response.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			if choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				a.emit(Event{Type: EventContent, Text: choice.Delta.Content})
+			}
+			for _, tc := range choice.Delta.ToolCalls {
+				i := len(msg.ToolCalls)
+				if tc.Index != nil {
+					i = *tc.Index
+				}
+				for len(msg.ToolCalls) <= i {
+					msg.ToolCalls = append(msg.ToolCalls, openai.ToolCall{Type: openai.ToolTypeFunction})
+				}
+				call := &msg.ToolCalls[i]
+				if tc.ID != "" {
+					call.ID = tc.ID
+				}
+				call.Function.Name += tc.Function.Name
+				call.Function.Arguments += tc.Function.Arguments
+			}
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+		}
+	}
+
+	if content.Len() == 0 && len(msg.ToolCalls) == 0 && finishReason == "" {
+		return openai.ChatCompletionResponse{}, fmt.Errorf("empty model stream")
+	}
+	msg.Content = content.String()
+	response.Choices = []openai.ChatCompletionChoice{{Index: 0, Message: msg, FinishReason: finishReason}}
+	return response, nil
+}
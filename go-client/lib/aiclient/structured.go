@@ -0,0 +1,272 @@
+package aiclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-client/lib/appconfig"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+const (
+	ResponseFormatJSONSchema = "json_schema"
+	ResponseFormatJSONObject = "json_object"
+
+	defaultRepairRetries = 1
+)
+
+var codeFence = regexp.MustCompile("(?s)^```[a-zA-Z]*\\s*(.*?)\\s*```$")
+
+// schemaKeywords are the JSON Schema keywords jsonschema.Definition keeps,
+// any other keyword would be dropped without constraining the answer.
+var schemaKeywords = map[string]bool{
+	"type": true, "description": true, "enum": true, "properties": true, "required": true,
+	"items": true, "additionalProperties": true, "nullable": true, "$ref": true, "$defs": true,
+}
+
+func init() {
+	// a response schema keyword the model wouldn't get makes the config invalid
+	appconfig.RegisterCheck(func(c *appconfig.AppConfig) appconfig.ValidationErrors {
+		var errs appconfig.ValidationErrors
+		for name, chat := range c.AiChatCfg {
+			if chat == nil || chat.ResponseFormat == nil || chat.ResponseFormat.Schema == nil {
+				continue
+			}
+			for _, err := range CheckSchema(chat.ResponseFormat.Schema) {
+				path := "chats." + name + ".responseFormat.schema"
+				if err.Path != "" {
+					path += "." + err.Path
+				}
+				errs = append(errs, appconfig.ValidationError{Path: path, Message: err.Message})
+			}
+		}
+		return errs
+	})
+}
+
+// SchemaError is a problem of a response schema at a dot separated Path, e.g. "properties.age.minimum".
+type SchemaError struct {
+	Path    string
+	Message string
+}
+
+func (e SchemaError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// CheckSchema reports keywords and types of schema SchemaDefinition can't convert.
+func CheckSchema(schema map[string]interface{}) []SchemaError {
+	var errs []SchemaError
+	normalizeSchema(schema, nil, &errs)
+	return errs
+}
+
+// normalizeSchema copies a schema with a nullable type list, e.g. [string, "null"],
+// replaced by the type and nullable: true, collecting unsupported parts into errs.
+func normalizeSchema(schema map[string]interface{}, path []string, errs *[]SchemaError) map[string]interface{} {
+	report := func(path []string, format string, args ...interface{}) {
+		*errs = append(*errs, SchemaError{Path: strings.Join(path, "."), Message: fmt.Sprintf(format, args...)})
+	}
+	sub := func(value interface{}, path []string) interface{} {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			report(path, "schema must be an object")
+			return value
+		}
+		return normalizeSchema(m, path, errs)
+	}
+	subs := func(value interface{}, path []string) interface{} {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			report(path, "must be an object of schemas")
+			return value
+		}
+		out := make(map[string]interface{}, len(m))
+		for _, name := range slices.Sorted(maps.Keys(m)) {
+			out[name] = sub(m[name], append(slices.Clone(path), name))
+		}
+		return out
+	}
+
+	out := make(map[string]interface{}, len(schema))
+	for _, key := range slices.Sorted(maps.Keys(schema)) {
+		value := schema[key]
+		keyPath := append(slices.Clone(path), key)
+		if !schemaKeywords[key] {
+			report(keyPath, "unsupported schema keyword %s", key)
+			continue
+		}
+		switch key {
+		case "type":
+			out[key] = value
+			types, ok := value.([]interface{})
+			if !ok {
+				continue
+			}
+			var nonNull []interface{}
+			for _, t := range types {
+				if t != string(jsonschema.Null) {
+					nonNull = append(nonNull, t)
+				}
+			}
+			if len(nonNull) != 1 {
+				report(keyPath, "type list must be one type and \"null\", got %v", types)
+				continue
+			}
+			out[key] = nonNull[0]
+			if len(types) > 1 {
+				out["nullable"] = true
+			}
+		case "properties", "$defs":
+			out[key] = subs(value, keyPath)
+		case "items":
+			out[key] = sub(value, keyPath)
+		case "additionalProperties":
+			if _, ok := value.(bool); ok {
+				out[key] = value
+			} else {
+				out[key] = sub(value, keyPath)
+			}
+		default:
+			out[key] = value
+		}
+	}
+	return out
+}
+
+// SchemaDefinition converts a JSON Schema read from YAML into jsonschema.Definition.
+// Keywords the definition can't express are an error, see CheckSchema.
+func SchemaDefinition(schema map[string]interface{}) (jsonschema.Definition, error) {
+	var errs []SchemaError
+	schema = normalizeSchema(schema, nil, &errs)
+	if len(errs) > 0 {
+		return jsonschema.Definition{}, fmt.Errorf("invalid schema: %w", errs[0])
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return jsonschema.Definition{}, err
+	}
+	var definition jsonschema.Definition
+	if err := json.Unmarshal(data, &definition); err != nil {
+		return jsonschema.Definition{}, fmt.Errorf("invalid schema: %v", err)
+	}
+	return definition, nil
+}
+
+var thinkTag = regexp.MustCompile(`(?s)<think>.*?</think>`)
+
+// StripThink removes <think> content of reasoning models from an answer.
+func StripThink(answer string) string {
+	return strings.TrimSpace(thinkTag.ReplaceAllString(answer, ""))
+}
+
+// CleanJSON strips <think> content and a markdown code block around a JSON answer.
+func CleanJSON(answer string) string {
+	answer = StripThink(answer)
+	if m := codeFence.FindStringSubmatch(answer); m != nil {
+		answer = m[1]
+	}
+	return answer
+}
+
+// initResponseFormat parses the response schema of the chat, if it has one.
+func (a *aiclient) initResponseFormat() error {
+	cfg := a.cfg.ResponseFormat
+	if cfg == nil {
+		return nil
+	}
+	switch cfg.Type {
+	case "", ResponseFormatJSONSchema, ResponseFormatJSONObject:
+	default:
+		return fmt.Errorf("unknown response format type %q, expected %s or %s", cfg.Type, ResponseFormatJSONSchema, ResponseFormatJSONObject)
+	}
+	if cfg.Schema == nil {
+		return fmt.Errorf("response format of chat %s has no schema", a.chatName)
+	}
+
+	schema, err := SchemaDefinition(cfg.Schema)
+	if err != nil {
+		return fmt.Errorf("response format of chat %s: %w", a.chatName, err)
+	}
+	a.responseSchema = &schema
+	return nil
+}
+
+// responseFormat asks the model for JSON: constrained by the schema
+// (structured output), or any JSON object for models without schema support.
+func (a *aiclient) responseFormat() *openai.ChatCompletionResponseFormat {
+	if a.responseSchema == nil {
+		return nil
+	}
+	cfg := a.cfg.ResponseFormat
+	if cfg.Type == ResponseFormatJSONObject {
+		return &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	}
+
+	name := cfg.Name
+	if name == "" {
+		name = a.chatName + "_response"
+	}
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   name,
+			Schema: a.responseSchema,
+			Strict: cfg.Strict,
+		},
+	}
+}
+
+// structuredAnswer validates the last answer against the response schema.
+// An invalid answer is sent back to the model with the problems found, at most
+// repairRetries times; only the valid answer stays in the history.
+func (a *aiclient) structuredAnswer(ctx context.Context, answer string) (string, error) {
+	retries := a.cfg.ResponseFormat.RepairRetries
+	if retries <= 0 {
+		retries = defaultRepairRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		data := CleanJSON(answer)
+		err := ValidateJSON(*a.responseSchema, data)
+		if err == nil {
+			a.messages[len(a.messages)-1].Content = data
+			return data, nil
+		}
+		if attempt >= retries {
+			return "", fmt.Errorf("answer doesn't match response format after %d repair(s): %w", retries, err)
+		}
+
+		a.logger().WarnContext(ctx, "answer doesn't match response format, asking for repair", "err", err)
+		a.turn.Repairs++
+		answerIdx := len(a.messages) - 1
+		a.messages = append(a.messages, openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleUser,
+			Content: fmt.Sprintf("Your answer doesn't match the required JSON schema: %v. "+
+				"Answer again with only the corrected JSON, no other text.", err),
+		})
+
+		response, err := a.createChatCompletion(ctx, openai.ChatCompletionRequest{
+			Model:          a.cfg.Model,
+			Temperature:    a.cfg.Temperature,
+			Messages:       a.messages,
+			ResponseFormat: a.responseFormat(),
+		})
+		if err != nil {
+			return "", err
+		}
+
+		// the repaired answer replaces the invalid one and the repair request
+		a.messages = append(a.messages[:answerIdx], response.Choices[0].Message)
+		answer = response.Choices[0].Message.Content
+	}
+}
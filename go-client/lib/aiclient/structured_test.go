@@ -0,0 +1,130 @@
+package aiclient
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-client/lib/appconfig"
+	"go-client/lib/fakellm"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestCleanJSON(t *testing.T) {
+	tests := map[string]string{
+		`{"a": 1}`:                          `{"a": 1}`,
+		"```json\n{\"a\": 1}\n```":          `{"a": 1}`,
+		"<think>hmm</think>\n```\n[1]\n```": `[1]`,
+	}
+	for input, expected := range tests {
+		if got := CleanJSON(input); got != expected {
+			t.Errorf("%q: expected %q, got %q", input, expected, got)
+		}
+	}
+}
+
+func TestAsk_StructuredRepairFails(t *testing.T) {
+	llm := fakellm.New()
+	defer llm.Close()
+	llm.On(`.*`).Reply(`{"name": 42}`)
+	t.Setenv("OPENAI_URL", llm.URL())
+
+	appconfig.Set(&appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"waiter": {
+				Model: "fake",
+				ResponseFormat: &appconfig.ResponseFormatConfig{
+					Strict:        true,
+					RepairRetries: 2,
+					Schema: map[string]interface{}{
+						"type":       "object",
+						"properties": map[string]interface{}{"name": map[string]interface{}{"type": "string"}},
+					},
+				},
+			},
+		},
+	})
+	a := newSession(t, "test-session", "waiter")
+
+	if _, err := a.Ask(context.Background(), "suggest a drink"); err == nil {
+		t.Fatal("expected error for answer not matching schema, got nil")
+	}
+	if turn := a.LastTurn(); turn.Repairs != 2 {
+		t.Errorf("expected 2 repairs, got %d", turn.Repairs)
+	}
+
+	requests := llm.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("expected answer and 2 repair requests, got %d", len(requests))
+	}
+	format := requests[0].ResponseFormat
+	if format == nil || format.Type != openai.ChatCompletionResponseFormatTypeJSONSchema || format.JSONSchema.Name != "waiter_response" || !format.JSONSchema.Strict {
+		t.Errorf("unexpected response format: %+v", format)
+	}
+	if n := len(requests[2].Messages); n != 4 {
+		t.Errorf("expected invalid answers replaced in history, got %d messages", n)
+	}
+}
+
+func TestSchemaDefinition_NullableType(t *testing.T) {
+	definition, err := SchemaDefinition(map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"glass": map[string]interface{}{"type": []interface{}{"string", "null"}}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if glass := definition.Properties["glass"]; glass.Type != "string" || !glass.Nullable {
+		t.Errorf("expected nullable string, got %+v", glass)
+	}
+	if err := ValidateJSON(definition, `{"glass": null}`); err != nil {
+		t.Errorf("expected null to be valid, got: %v", err)
+	}
+}
+
+func TestParseConfig_UnsupportedSchemaKeywords(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	yamlContent := `
+store:
+  type: memory
+chats:
+  waiter:
+    model: "qwen3:1.7b"
+    responseFormat:
+      schema:
+        type: object
+        properties:
+          abv:
+            type: number
+            minimum: 0
+          name:
+            type: [string, integer]
+          tags:
+            type: array
+            items:
+              type: string
+              pattern: "^[a-z]+$"
+        oneOf: []
+`
+	if err := os.WriteFile(configPath, []byte(yamlContent), 0644); err != nil {
+		t.Fatalf("failed to write temp config file: %v", err)
+	}
+
+	_, err := appconfig.ParseConfig(configPath)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	for _, want := range []string{
+		"line 13: chats.waiter.responseFormat.schema.properties.abv.minimum: unsupported schema keyword minimum",
+		`line 15: chats.waiter.responseFormat.schema.properties.name.type: type list must be one type and "null", got [string integer]`,
+		"line 20: chats.waiter.responseFormat.schema.properties.tags.items.pattern: unsupported schema keyword pattern",
+		"line 21: chats.waiter.responseFormat.schema.oneOf: unsupported schema keyword oneOf",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error %q, got:\n%v", want, err)
+		}
+	}
+}
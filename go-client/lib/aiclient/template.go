@@ -0,0 +1,196 @@
+package aiclient
+
+import (
+	"context"
+	"fmt"
+	"go-client/lib/appconfig"
+	"go-client/lib/examples"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// PromptData is available in prompt section templates, e.g.
+// {{.Date}}, {{.User.name}}, {{range .Tools}}{{.Name}}{{end}}.
+type PromptData struct {
+	Date      string             // current date, YYYY-MM-DD
+	Now       time.Time          // current time, e.g. {{.Now.Format "Monday"}}
+	SessionID string             // chat session ID
+	Chat      string             // chat name in config
+	User      map[string]string  // user profile
+	Tools     []PromptTool       // tools available to the chat
+	Retrieved string             // context retrieved for the current request
+	Examples  []examples.Example // examples similar to the current request, appended to [EXAMPLES]
+	Vars      map[string]string  // prompt vars from config
+}
+
+// RequestPrompt is prompt data sent with a user message: user profile
+// fields (.User) and context the caller retrieved for the message (.Retrieved).
+type RequestPrompt struct {
+	User      map[string]string
+	Retrieved string
+}
+
+type requestPromptKey struct{}
+
+// WithRequestPrompt gives Ask prompt data of the request. For that turn
+// its fields replace the session's user profile fields and retrieved context.
+func WithRequestPrompt(ctx context.Context, p RequestPrompt) context.Context {
+	return context.WithValue(ctx, requestPromptKey{}, p)
+}
+
+func requestPromptFrom(ctx context.Context) RequestPrompt {
+	p, _ := ctx.Value(requestPromptKey{}).(RequestPrompt)
+	return p
+}
+
+type PromptTool struct {
+	Name        string
+	Description string
+}
+
+type sectionTemplate struct {
+	name string
+	tmpl *template.Template
+}
+
+// PromptTemplate is a parsed system prompt of a chat.
+type PromptTemplate struct {
+	sections []sectionTemplate
+}
+
+func init() {
+	// a broken prompt template or a missing prompt file makes the config invalid
+	appconfig.RegisterCheck(func(c *appconfig.AppConfig) appconfig.ValidationErrors {
+		var errs appconfig.ValidationErrors
+		for name, chat := range c.AiChatCfg {
+			if chat == nil {
+				continue
+			}
+			if _, err := chatPromptTemplate(c, name); err != nil {
+				errs = append(errs, appconfig.ValidationError{Path: "chats." + name + ".prompt", Message: err.Error()})
+			}
+		}
+		return errs
+	})
+}
+
+// promptTemplateKey is the chat name of a prompt template derived from a config.
+type promptTemplateKey string
+
+type parsedPromptTemplate struct {
+	tmpl *PromptTemplate
+	err  error
+}
+
+// chatPromptTemplate returns the prompt template of a chat parsed once per config,
+// so a prompt file broken or deleted later doesn't fail sessions of that config.
+func chatPromptTemplate(appCfg *appconfig.AppConfig, chatName string) (*PromptTemplate, error) {
+	parsed := appCfg.Derived(promptTemplateKey(chatName), func() any {
+		chat, ok := appCfg.AiChatCfg[chatName]
+		if !ok || chat == nil {
+			return parsedPromptTemplate{err: fmt.Errorf("Configuration for chat \"%s\" not found", chatName)}
+		}
+		tmpl, err := ParsePromptTemplate(chat.Prompt)
+		return parsedPromptTemplate{tmpl: tmpl, err: err}
+	}).(parsedPromptTemplate)
+	return parsed.tmpl, parsed.err
+}
+
+// ParsePromptTemplate reads file sections and parses all section templates.
+func ParsePromptTemplate(cfg appconfig.AiChatConfigPrompt) (*PromptTemplate, error) {
+	t := &PromptTemplate{}
+
+	fixed := []struct {
+		name    string
+		section appconfig.PromptSection
+	}{
+		{"ROLE", cfg.Role},
+		{"CONTEXT", cfg.Context},
+		{"EXAMPLES", cfg.Examples},
+		{"TASK", cfg.Task},
+		{"INSTRUCTIONS", cfg.Instructions},
+	}
+	for _, f := range fixed {
+		if err := t.add(f.name, f.section); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, s := range cfg.Sections {
+		if s.Name == "" {
+			return nil, fmt.Errorf("prompt section #%d has no name", i+1)
+		}
+		if err := t.add(strings.ToUpper(s.Name), s); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (t *PromptTemplate) add(name string, s appconfig.PromptSection) error {
+	if s.IsEmpty() {
+		return nil
+	}
+
+	text := s.Text
+	if s.File != "" {
+		data, err := os.ReadFile(s.File)
+		if err != nil {
+			return fmt.Errorf("prompt section %s: %w", name, err)
+		}
+		text = string(data)
+	}
+
+	// missing map keys (e.g. not set user profile fields) render empty
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return fmt.Errorf("prompt section %s: %w", name, err)
+	}
+	t.sections = append(t.sections, sectionTemplate{name: name, tmpl: tmpl})
+	return nil
+}
+
+// Render executes section templates and joins them into the system prompt.
+func (t *PromptTemplate) Render(data PromptData) (string, error) {
+	p := PromptBuilder()
+	staticExamples := ""
+	for _, s := range t.sections {
+		var builder strings.Builder
+		if err := s.tmpl.Execute(&builder, data); err != nil {
+			return "", fmt.Errorf("prompt section %s: %w", s.name, err)
+		}
+		text := builder.String()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		switch s.name {
+		case "ROLE":
+			p.WithRole(text)
+		case "CONTEXT":
+			p.WithContext(text)
+		case "EXAMPLES":
+			staticExamples = text
+		case "TASK":
+			p.WithTask(text)
+		case "INSTRUCTIONS":
+			p.WithInstructions(text)
+		default:
+			p.WithSection(s.name, text)
+		}
+	}
+
+	// selected examples follow the configured ones
+	exampleTexts := []string{}
+	if staticExamples != "" {
+		exampleTexts = append(exampleTexts, strings.TrimRight(staticExamples, "\n"))
+	}
+	if len(data.Examples) > 0 {
+		exampleTexts = append(exampleTexts, strings.TrimRight(examples.Format(data.Examples), "\n"))
+	}
+	p.WithExamples(strings.Join(exampleTexts, "\n\n"))
+
+	return p.Get(), nil
+}
@@ -0,0 +1,92 @@
+package aiclient
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-client/lib/appconfig"
+)
+
+func TestPromptTemplate_Render(t *testing.T) {
+	roleFile := filepath.Join(t.TempDir(), "role.md")
+	if err := os.WriteFile(roleFile, []byte("You serve {{.User.name}} at {{.Vars.bar}}."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := ParsePromptTemplate(appconfig.AiChatConfigPrompt{
+		Role:         appconfig.PromptSection{File: roleFile},
+		Task:         appconfig.PromptSection{Text: "{{if .Retrieved}}{{.Retrieved}}{{end}}"},
+		Instructions: appconfig.PromptSection{Text: "Tools:{{range .Tools}} {{.Name}}{{end}}"},
+		Sections:     []appconfig.PromptSection{{Name: "session", Text: "{{.SessionID}}"}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	prompt, err := tmpl.Render(PromptData{
+		SessionID: "s1",
+		Tools:     []PromptTool{{Name: "cocktail_list"}, {Name: "cocktail_recipe"}},
+		Vars:      map[string]string{"bar": "Cocktail Bar"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// missing user name renders empty, empty task section is skipped
+	expected := "[ROLE]\nYou serve  at Cocktail Bar.\n\n" +
+		"[INSTRUCTIONS]\nTools: cocktail_list cocktail_recipe\n\n" +
+		"[SESSION]\ns1\n\n"
+	if prompt != expected {
+		t.Errorf("expected:\n%q\ngot:\n%q", expected, prompt)
+	}
+}
+
+func TestRenderSystemPrompt(t *testing.T) {
+	cassettes := t.TempDir()
+	cfg := &appconfig.AppConfig{
+		AiChatCfg: map[string]*appconfig.AiChatConfig{
+			"waiter": {
+				Model:              "fake",
+				AvailableFunctions: []string{"get_current_time"},
+				Prompt: appconfig.AiChatConfigPrompt{
+					Role: appconfig.PromptSection{Text: "You serve {{.User.name}} in session {{.SessionID}}."},
+					Task: appconfig.PromptSection{Text: "{{.Retrieved}}{{range .Tools}} {{.Name}}{{end}}"},
+				},
+			},
+		},
+		Cassette: &appconfig.CassetteConfig{Mode: CassetteRecord, Dir: cassettes},
+	}
+	ctx := appconfig.WithConfig(context.Background(), cfg)
+
+	prompt, err := RenderSystemPrompt(ctx, "waiter", "render", RequestPrompt{User: map[string]string{"name": "Ala"}, Retrieved: "Ala likes rum"}, "")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	expected := "[ROLE]\nYou serve Ala in session render.\n\n[TASK]\nAla likes rum get_current_time\n\n"
+	if prompt != expected {
+		t.Errorf("expected:\n%q\ngot:\n%q", expected, prompt)
+	}
+	// rendering isn't a session, nothing is recorded
+	if files, _ := os.ReadDir(cassettes); len(files) != 0 {
+		t.Errorf("expected no cassette, got %v", files)
+	}
+
+	if _, err := RenderSystemPrompt(ctx, "nobody", "render", RequestPrompt{}, ""); err == nil {
+		t.Error("expected error for unknown chat, got nil")
+	}
+}
+
+func TestParsePromptTemplate_Errors(t *testing.T) {
+	tests := map[string]appconfig.AiChatConfigPrompt{
+		"missing file":   {Role: appconfig.PromptSection{File: "no/such/file.md"}},
+		"bad template":   {Role: appconfig.PromptSection{Text: "{{.Date"}},
+		"unnamed custom": {Sections: []appconfig.PromptSection{{Text: "text"}}},
+	}
+	for name, cfg := range tests {
+		if _, err := ParsePromptTemplate(cfg); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package aiclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// validateArguments decodes tool call arguments and checks them against the
+// tool's declared schema. All problems found are reported in one error, so
+// the model can fix them in a single retry.
+func validateArguments(schema jsonschema.Definition, arguments string) (map[string]interface{}, error) {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(arguments), &value); err != nil {
+		return nil, fmt.Errorf("arguments are not valid JSON: %v", err)
+	}
+
+	if problems := validateValue(schema, value, "arguments"); len(problems) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	args, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("arguments must be a JSON object")
+	}
+	return args, nil
+}
+
+// ValidateJSON checks a JSON document against a schema and reports all problems found.
+func ValidateJSON(schema jsonschema.Definition, data string) error {
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		return fmt.Errorf("not valid JSON: %v", err)
+	}
+	if problems := validateValue(schema, value, "$"); len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func validateValue(schema jsonschema.Definition, value interface{}, path string) []string {
+	if value == nil {
+		if schema.Nullable || schema.Type == jsonschema.Null || schema.Type == "" {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s must be %s, got null", path, schema.Type)}
+	}
+
+	var problems []string
+
+	switch schema.Type {
+	case jsonschema.Object:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{typeMismatch(path, schema.Type, value)}
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s is required", path, name))
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			propSchema, ok := schema.Properties[name]
+			if !ok {
+				if schema.AdditionalProperties == false {
+					problems = append(problems, fmt.Sprintf("%s.%s is not allowed", path, name))
+				}
+				continue
+			}
+			problems = append(problems, validateValue(propSchema, obj[name], path+"."+name)...)
+		}
+	case jsonschema.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{typeMismatch(path, schema.Type, value)}
+		}
+		if schema.Items != nil {
+			for i, item := range items {
+				problems = append(problems, validateValue(*schema.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case jsonschema.String:
+		s, ok := value.(string)
+		if !ok {
+			return []string{typeMismatch(path, schema.Type, value)}
+		}
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, s) {
+			problems = append(problems, fmt.Sprintf("%s must be one of [%s], got %q", path, strings.Join(schema.Enum, ", "), s))
+		}
+	case jsonschema.Number:
+		if _, ok := value.(float64); !ok {
+			return []string{typeMismatch(path, schema.Type, value)}
+		}
+	case jsonschema.Integer:
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return []string{typeMismatch(path, schema.Type, value)}
+		}
+	case jsonschema.Boolean:
+		if _, ok := value.(bool); !ok {
+			return []string{typeMismatch(path, schema.Type, value)}
+		}
+	case jsonschema.Null:
+		return []string{typeMismatch(path, schema.Type, value)}
+	}
+
+	return problems
+}
+
+func typeMismatch(path string, expected jsonschema.DataType, value interface{}) string {
+	return fmt.Sprintf("%s must be %s, got %s", path, expected, jsonTypeName(value))
+}
+
+func jsonTypeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,61 @@
+package aiclient
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+var testSchema = jsonschema.Definition{
+	Type: jsonschema.Object,
+	Properties: map[string]jsonschema.Definition{
+		"name":  {Type: jsonschema.String},
+		"limit": {Type: jsonschema.Integer},
+		"mode":  {Type: jsonschema.String, Enum: []string{"fast", "slow"}},
+	},
+	Required: []string{"name"},
+}
+
+func TestValidateArguments_Valid(t *testing.T) {
+	args, err := validateArguments(testSchema, `{"name": "Negroni", "limit": 3, "mode": "fast"}`)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if args["name"] != "Negroni" {
+		t.Errorf("unexpected args: %+v", args)
+	}
+}
+
+func TestValidateArguments_EmptyArguments(t *testing.T) {
+	schema := jsonschema.Definition{Type: jsonschema.Object, Properties: map[string]jsonschema.Definition{}}
+	if _, err := validateArguments(schema, ""); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+}
+
+func TestValidateArguments_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		arguments string
+		expected  string
+	}{
+		"missing required": {`{"limit": 3}`, "arguments.name is required"},
+		"wrong type":       {`{"name": 5}`, "arguments.name must be string, got integer"},
+		"not integer":      {`{"name": "a", "limit": 1.5}`, "arguments.limit must be integer, got number"},
+		"enum":             {`{"name": "a", "mode": "medium"}`, "arguments.mode must be one of [fast, slow]"},
+		"not json":         {`{"name": `, "not valid JSON"},
+		"not object":       {`["a"]`, "arguments must be object, got array"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := validateArguments(testSchema, tt.arguments)
+			if err == nil {
+				t.Fatal("expected validation error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got: %v", tt.expected, err)
+			}
+		})
+	}
+}
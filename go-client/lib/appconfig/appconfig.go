@@ -1,27 +1,104 @@
 package appconfig
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"go-client/lib/applog"
+	"log/slog"
 	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"gopkg.in/yaml.v3"
 )
 
+// PromptSection is a text/template of a system prompt section, given inline
+// (a plain YAML string) or read from File, relative to the config file
+// declaring it. Name is set for custom sections.
+type PromptSection struct {
+	Name string `yaml:"name"`
+	Text string `yaml:"text"`
+	File string `yaml:"file"`
+}
+
+// UnmarshalYAML accepts a string as the inline template.
+func (s *PromptSection) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		s.Text = node.Value
+		return nil
+	}
+	// node.Decode doesn't inherit KnownFields from the config decoder
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Value != "name" && key.Value != "text" && key.Value != "file" {
+				return fmt.Errorf("line %d: field %s not found in prompt section", key.Line, key.Value)
+			}
+		}
+	}
+	type section PromptSection
+	return node.Decode((*section)(s))
+}
+
+// IsEmpty tells if the section has no template.
+func (s PromptSection) IsEmpty() bool {
+	return s.Text == "" && s.File == ""
+}
+
+// AiChatConfigPrompt defines system prompt sections, the five fixed ones
+// followed by custom Sections. Vars are available in templates as .Vars.
 type AiChatConfigPrompt struct {
-	Role         string `yaml:"role"`
-	Context      string `yaml:"context"`
-	Examples     string `yaml:"examples"`
-	Task         string `yaml:"task"`
-	Instructions string `yaml:"instructions"`
+	Role         PromptSection     `yaml:"role"`
+	Context      PromptSection     `yaml:"context"`
+	Examples     PromptSection     `yaml:"examples"`
+	Task         PromptSection     `yaml:"task"`
+	Instructions PromptSection     `yaml:"instructions"`
+	Sections     []PromptSection   `yaml:"sections"`
+	Vars         map[string]string `yaml:"vars"`
+}
+
+// ExamplesConfig enables dynamic few-shot examples: K examples most similar
+// to the user message are put into the [EXAMPLES] section every turn.
+// Distance > 0 drops examples with a larger vector distance.
+type ExamplesConfig struct {
+	K        int     `yaml:"k"`
+	Distance float32 `yaml:"distance"`
+}
+
+// ResponseFormatConfig makes a chat answer with JSON valid against Schema.
+// Type json_schema (default) uses structured output constrained by the schema,
+// json_object only JSON mode. Invalid answers are sent back for repair at
+// most RepairRetries times (default 1).
+type ResponseFormatConfig struct {
+	Type          string                 `yaml:"type"`
+	Name          string                 `yaml:"name"`
+	Strict        bool                   `yaml:"strict"`
+	Schema        map[string]interface{} `yaml:"schema"`
+	RepairRetries int                    `yaml:"repairRetries"`
+}
+
+// BudgetConfig limits tokens a chat may use, stopping runaway tool loops.
+// No model request is sent once TurnTokens were used answering one message,
+// or SessionTokens in the whole session. Zero means no limit.
+type BudgetConfig struct {
+	TurnTokens    int `yaml:"turnTokens"`
+	SessionTokens int `yaml:"sessionTokens"`
 }
 
 type AiChatConfig struct {
-	Model              string             `yaml:"model"`
-	Temperature        float32            `yaml:"temperature"`
-	Prompt             AiChatConfigPrompt `yaml:"prompt"`
-	AvailableFunctions []string           `yaml:"availableFunctions"`
-	TmpHttpPort        int                `yaml:"tmpHttpPort"`
+	Model              string                `yaml:"model"`
+	Temperature        float32               `yaml:"temperature"`
+	Prompt             AiChatConfigPrompt    `yaml:"prompt"`
+	AvailableFunctions []string              `yaml:"availableFunctions"`
+	TmpHttpPort        int                   `yaml:"tmpHttpPort"`
+	ToolConcurrency    int                   `yaml:"toolConcurrency"`
+	MaxToolRounds      int                   `yaml:"maxToolRounds"`
+	Examples           ExamplesConfig        `yaml:"examples"`
+	ResponseFormat     *ResponseFormatConfig `yaml:"responseFormat"`
+	Budget             BudgetConfig          `yaml:"budget"`
 }
 
 type FunctionConfig struct {
@@ -29,28 +106,311 @@ type FunctionConfig struct {
 	Description string `yaml:"description"`
 }
 
+// SearchConfig tunes cocktail retrieval for a tool.
+// Mode is one of near-text, bm25, hybrid, name. Alpha weights hybrid
+// search: 0 is pure BM25, 1 is pure vector search.
+type SearchConfig struct {
+	Mode     string   `yaml:"mode"`
+	Alpha    *float32 `yaml:"alpha"`
+	Limit    int      `yaml:"limit"`
+	Distance float32  `yaml:"distance"`
+}
+
+// RerankConfig enables re-ranking of retrieved items. Method is llm
+// (Model rates every candidate) or keyword (local word matching), empty
+// disables it. Candidates is how many items are fetched before re-ranking,
+// at least the search limit (default 3 times the limit).
+type RerankConfig struct {
+	Method     string `yaml:"method"`
+	Model      string `yaml:"model"`
+	Candidates int    `yaml:"candidates"`
+}
+
+// RewriteConfig enables rewriting of a user request by Model into
+// at most Queries search queries with ingredient exclusions.
+type RewriteConfig struct {
+	Model   string `yaml:"model"`
+	Queries int    `yaml:"queries"`
+}
+
+// ToolConfig holds settings of a built-in tool function.
+type ToolConfig struct {
+	Search  SearchConfig  `yaml:"search"`
+	Rewrite RewriteConfig `yaml:"rewrite"`
+	Rerank  RerankConfig  `yaml:"rerank"`
+}
+
+// CollectionConfig selects how vectors of a Weaviate collection are computed.
+// Vectorizer "none" means vectors come from EmbeddingModel, called by the app;
+// any other value (default text2vec-transformers) is a Weaviate vectorizer module.
+type CollectionConfig struct {
+	Vectorizer     string `yaml:"vectorizer"`
+	EmbeddingModel string `yaml:"embeddingModel"`
+}
+
 type WeaviateConfig struct {
-	Scheme string `yaml:"scheme"`
-	Host   string `yaml:"host"`
+	Scheme      string                       `yaml:"scheme"`
+	Host        string                       `yaml:"host"`
+	Collections map[string]*CollectionConfig `yaml:"collections"`
+}
+
+// Collection returns settings of a collection, empty if the collection isn't configured.
+func (c *WeaviateConfig) Collection(name string) *CollectionConfig {
+	if c == nil {
+		return &CollectionConfig{}
+	}
+	if coll, ok := c.Collections[name]; ok && coll != nil {
+		return coll
+	}
+	return &CollectionConfig{}
+}
+
+// StoreConfig selects where cocktails are kept. Type is weaviate (default)
+// or memory: an in-process store loaded from the Data CSV file at start,
+// with vectors from EmbeddingModel, no Weaviate needed. In memory mode chat
+// examples are kept in ExamplesDir/<chat>.jsonl (default data/examples).
+type StoreConfig struct {
+	Type           string `yaml:"type"`
+	Data           string `yaml:"data"`
+	EmbeddingModel string `yaml:"embeddingModel"`
+	ExamplesDir    string `yaml:"examplesDir"`
+}
+
+// CassetteConfig records chat sessions or replays them. Mode is record
+// (a file per session in Dir) or replay (File served instead of the model
+// and tools), empty disables it.
+type CassetteConfig struct {
+	Mode string `yaml:"mode"`
+	Dir  string `yaml:"dir"`
+	File string `yaml:"file"`
+}
+
+// OpenAIConfig is the OpenAI-compatible API serving models, e.g. Ollama.
+// Token can be read from TokenFile instead, e.g. a docker secret.
+type OpenAIConfig struct {
+	URL       string `yaml:"url"`
+	Token     Secret `yaml:"token"`
+	TokenFile string `yaml:"token_file"`
+}
+
+// PriceConfig is the price of a model in USD per 1M tokens.
+type PriceConfig struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
+// Cost returns the price of a model request.
+func (p PriceConfig) Cost(promptTokens int, completionTokens int) float64 {
+	return (float64(promptTokens)*p.Input + float64(completionTokens)*p.Output) / 1e6
 }
 
 type AppConfig struct {
 	AiChatCfg   map[string]*AiChatConfig   `yaml:"chats"`
 	FunctionCfg map[string]*FunctionConfig `yaml:"functions"`
+	ToolCfg     map[string]*ToolConfig     `yaml:"tools"`
 	Weaviate    *WeaviateConfig            `yaml:"weaviate"`
+	Store       *StoreConfig               `yaml:"store"`
+	Cassette    *CassetteConfig            `yaml:"cassette"`
+	OpenAI      *OpenAIConfig              `yaml:"openai"`
+	Pricing     map[string]PriceConfig     `yaml:"pricing"` // per model, unpriced models cost nothing
+	Log         *applog.Config             `yaml:"log"`
+
+	// Include lists files merged into this one, glob patterns relative to
+	// the including file, e.g. agents/*.yaml
+	Include []string `yaml:"include"`
+
+	files   []string              // read to build the config, see Files
+	globs   []string              // include patterns, matched again by Watcher
+	sources map[*yaml.Node]string // file of every config node
+	derived sync.Map              // values made from the config, see Derived
+}
+
+// Derived returns the value fn makes from this config, e.g. parsed prompt
+// templates. It is made once per key and kept with the config, so files fn
+// reads are read again only for a new config, not for every session.
+func (c *AppConfig) Derived(key any, fn func() any) any {
+	if v, ok := c.derived.Load(key); ok {
+		return v
+	}
+	v, _ := c.derived.LoadOrStore(key, fn())
+	return v
+}
+
+// Files returns files the config was read from: the config file, included
+// files and prompt section files.
+func (c *AppConfig) Files() []string {
+	return c.files
+}
+
+// promptFiles lists prompt section files of all chats, sorted and unique.
+func (c *AppConfig) promptFiles() []string {
+	files := []string{}
+	for _, name := range sortedKeys(c.AiChatCfg) {
+		chat := c.AiChatCfg[name]
+		if chat == nil {
+			continue
+		}
+		p := chat.Prompt
+		for _, s := range append([]PromptSection{p.Role, p.Context, p.Examples, p.Task, p.Instructions}, p.Sections...) {
+			if s.File != "" && !slices.Contains(files, s.File) {
+				files = append(files, s.File)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// OpenAIAPI returns the model API settings, values missing in config are
+// taken from OPENAI_URL and OPENAI_API_TOKEN environment variables.
+func (c *AppConfig) OpenAIAPI() OpenAIConfig {
+	api := OpenAIConfig{}
+	if c != nil && c.OpenAI != nil {
+		api = *c.OpenAI
+	}
+	if api.URL == "" {
+		api.URL = os.Getenv("OPENAI_URL")
+	}
+	if api.Token == "" {
+		api.Token = Secret(os.Getenv("OPENAI_API_TOKEN"))
+	}
+	return api
+}
+
+// LogConfig returns the logging settings, defaults if there is no "log" block.
+func (c *AppConfig) LogConfig() applog.Config {
+	if c == nil || c.Log == nil {
+		return applog.Config{}
+	}
+	return *c.Log
 }
 
-var AppCfg *AppConfig
+// readSecrets loads *_file secret references.
+func (c *AppConfig) readSecrets() error {
+	if c.OpenAI != nil && c.OpenAI.TokenFile != "" {
+		if c.OpenAI.Token != "" {
+			return fmt.Errorf("openai: token and token_file can't be both set")
+		}
+		data, err := os.ReadFile(c.OpenAI.TokenFile)
+		if err != nil {
+			return fmt.Errorf("openai.token_file: %w", err)
+		}
+		c.OpenAI.Token = Secret(strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// Tool returns settings of a built-in tool, empty if the tool isn't configured.
+func (c *AppConfig) Tool(name string) *ToolConfig {
+	if t, ok := c.ToolCfg[name]; ok && t != nil {
+		return t
+	}
+	return &ToolConfig{}
+}
+
+// current is the running config, replaced as a whole on reload and never
+// modified, so a config taken by a session stays consistent.
+var current atomic.Pointer[AppConfig]
+
+// overrides adjust every loaded config, e.g. with command line flags.
+var overrides []func(cfg *AppConfig)
+
+// Current returns the running config.
+func Current() *AppConfig {
+	return current.Load()
+}
+
+// Set replaces the running config.
+func Set(cfg *AppConfig) {
+	current.Store(cfg)
+	// secrets never get into logs, also ones from the environment
+	applog.SetSecrets(string(cfg.OpenAIAPI().Token))
+}
+
+// AddOverride registers a change applied to every config loaded from now on.
+func AddOverride(fn func(cfg *AppConfig)) {
+	overrides = append(overrides, fn)
+}
+
+type ctxKey struct{}
+
+// WithConfig returns a context carrying a session's config.
+func WithConfig(ctx context.Context, cfg *AppConfig) context.Context {
+	return context.WithValue(ctx, ctxKey{}, cfg)
+}
+
+// FromContext returns the config carried by ctx, the running one if there is none.
+func FromContext(ctx context.Context) *AppConfig {
+	if cfg, ok := ctx.Value(ctxKey{}).(*AppConfig); ok && cfg != nil {
+		return cfg
+	}
+	return Current()
+}
 
 func LoadConfig(path string) error {
-	log.Println("Load config file:", path)
-	data, err := os.ReadFile(path)
+	logger().Info("loading config", "file", path)
+	cfg, err := loadConfig(path)
 	if err != nil {
-		return fmt.Errorf("cannot read file %s: %w", path, err)
-	}
-	if err := yaml.Unmarshal(data, &AppCfg); err != nil {
-		return fmt.Errorf("YAML parsing error: %w", err)
+		return err
 	}
-	log.Println("Config file loaded")
+	Set(cfg)
+	logger().Info("config loaded", "files", cfg.Files())
 	return nil
 }
+
+func loadConfig(path string) (*AppConfig, error) {
+	cfg, err := ParseConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, fn := range overrides {
+		fn(cfg)
+	}
+	return cfg, nil
+}
+
+// ParseConfig reads and validates a config file. Unknown fields are errors,
+// so typos don't silently fall back to defaults. Validation problems are
+// returned together as ValidationErrors.
+//
+// ${NAME:-default} references are replaced with environment variables, then
+// EnvPrefix variables override values and *_file secrets are read.
+func ParseConfig(path string) (*AppConfig, error) {
+	files, globs, err := readConfigFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	root, sources, err := mergeConfigFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyEnvOverrides(root, os.Environ()); err != nil {
+		return nil, err
+	}
+	cfg := &AppConfig{}
+	if root.Kind != 0 {
+		if err := root.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("config with %s overrides: %w", EnvPrefix, err)
+		}
+	}
+	if err := cfg.readSecrets(); err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		cfg.files = append(cfg.files, file.path)
+	}
+	cfg.files = append(cfg.files, cfg.promptFiles()...)
+	cfg.globs = globs
+	cfg.sources = sources
+
+	if errs := cfg.Validate(root); len(errs) > 0 {
+		return nil, errs
+	}
+	return cfg, nil
+}
+
+func logger() *slog.Logger {
+	return applog.Component("config")
+}
@@ -1,11 +1,17 @@
 package appconfig
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
+func init() {
+	RegisterBuiltinFunctions("get_current_time", "cocktail_list", "cocktail_recipe")
+}
+
 func TestLoadConfig_Success(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "config.yaml")
@@ -22,11 +28,20 @@ chats:
     prompt:
       role: "test role"
       instructions: "test instructions"
+      sections:
+        - name: STYLE
+          text: "Be brief"
     tmpHttpPort: 3000
 functions:
   get_drink_recipe:
     url: "http://localhost:3002/api/ask"
     description: "recipe"
+tools:
+  cocktail_list:
+    search:
+      mode: hybrid
+      alpha: 0
+      limit: 7
 `
 	if err := os.WriteFile(configPath, []byte(yamlContent), 0644); err != nil {
 		t.Fatalf("failed to write temp config file: %v", err)
@@ -36,27 +51,93 @@ functions:
 		t.Fatalf("expected no error, got: %v", err)
 	}
 
-	if AppCfg == nil {
-		t.Fatal("expected AppCfg to be populated, got nil")
+	cfg := Current()
+	if cfg == nil {
+		t.Fatal("expected config to be populated, got nil")
 	}
 
-	if AppCfg.Weaviate == nil || AppCfg.Weaviate.Host != "localhost:8080" {
-		t.Errorf("unexpected Weaviate config: %+v", AppCfg.Weaviate)
+	if cfg.Weaviate == nil || cfg.Weaviate.Host != "localhost:8080" {
+		t.Errorf("unexpected Weaviate config: %+v", cfg.Weaviate)
 	}
 
-	if AppCfg.AiChatCfg == nil {
+	if cfg.AiChatCfg == nil {
 		t.Fatal("expected chats to be populated, got nil")
 	}
-	chat, ok := AppCfg.AiChatCfg["coordinator"]
+	chat, ok := cfg.AiChatCfg["coordinator"]
 	if !ok {
 		t.Fatal("expected coordinator chat to exist")
 	}
 	if chat.Model != "qwen3:1.7b" || chat.TmpHttpPort != 3000 {
 		t.Errorf("unexpected chat config: %+v", chat)
 	}
+	if chat.Prompt.Role.Text != "test role" || chat.Prompt.Instructions.Text != "test instructions" {
+		t.Errorf("unexpected prompt sections: %+v", chat.Prompt)
+	}
+	if len(chat.Prompt.Sections) != 1 || chat.Prompt.Sections[0].Name != "STYLE" {
+		t.Errorf("unexpected custom prompt sections: %+v", chat.Prompt.Sections)
+	}
 
-	if AppCfg.FunctionCfg == nil || AppCfg.FunctionCfg["get_drink_recipe"].Url == "" {
-		t.Errorf("unexpected FunctionCfg: %+v", AppCfg.FunctionCfg)
+	if cfg.FunctionCfg == nil || cfg.FunctionCfg["get_drink_recipe"].Url == "" {
+		t.Errorf("unexpected FunctionCfg: %+v", cfg.FunctionCfg)
+	}
+
+	search := cfg.Tool("cocktail_list").Search
+	if search.Mode != "hybrid" || search.Limit != 7 || search.Alpha == nil || *search.Alpha != 0 {
+		t.Errorf("unexpected cocktail_list search config: %+v", search)
+	}
+	if cfg.Tool("cocktail_recipe") == nil {
+		t.Error("expected empty config for not configured tool, got nil")
+	}
+}
+
+func TestParseConfig_PromptFilesRelativeToConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "agents"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"config.yaml": `
+store:
+  type: memory
+include: [agents/waiter.yaml]
+chats:
+  coordinator:
+    model: "qwen3:1.7b"
+    prompt:
+      role:
+        file: prompts/coordinator/role.md
+`,
+		"agents/waiter.yaml": `
+chats:
+  waiter:
+    model: "qwen3:1.7b"
+    prompt:
+      sections:
+        - name: STYLE
+          file: prompts/style.md
+      task:
+        file: /etc/prompts/task.md
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := ParseConfig(filepath.Join(tmpDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := cfg.AiChatCfg["coordinator"].Prompt.Role.File; got != filepath.Join(tmpDir, "prompts/coordinator/role.md") {
+		t.Errorf("expected file relative to config.yaml, got %s", got)
+	}
+	waiter := cfg.AiChatCfg["waiter"].Prompt
+	if got := waiter.Sections[0].File; got != filepath.Join(tmpDir, "agents/prompts/style.md") {
+		t.Errorf("expected file relative to the included file, got %s", got)
+	}
+	if waiter.Task.File != "/etc/prompts/task.md" {
+		t.Errorf("expected absolute file kept, got %s", waiter.Task.File)
 	}
 }
 
@@ -89,3 +170,196 @@ chats:
 		t.Fatal("expected YAML parsing error, got nil")
 	}
 }
+
+func TestParseConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		yaml   string
+		errors []string
+	}{
+		{
+			name: "unknown field",
+			yaml: `
+weaviate:
+  scheme: http
+  host: localhost:8080
+chats:
+  coordinator:
+    model: "qwen3:1.7b"
+    temperture: 0.5
+`,
+			errors: []string{"line 8: field temperture not found"},
+		},
+		{
+			name: "unknown prompt section field",
+			yaml: `
+store:
+  type: memory
+chats:
+  coordinator:
+    model: "qwen3:1.7b"
+    prompt:
+      role:
+        txt: "test role"
+`,
+			errors: []string{"line 9: field txt not found in prompt section"},
+		},
+		{
+			name: "semantic errors",
+			yaml: `
+weaviate:
+  scheme: tcp
+  host: localhost:8080
+chats:
+  coordinator:
+    model: "qwen3:1.7b"
+    availableFunctions:
+      - get_current_time
+      - get_wine_pairing
+    tmpHttpPort: 3000
+  waiter:
+    model: ""
+    temperature: 3
+    tmpHttpPort: 3000
+functions:
+  get_drink_recipe:
+    url: "localhost:3002/api/ask"
+tools:
+  cocktail_search:
+    search:
+      mode: hybrid
+`,
+			errors: []string{
+				"line 3: weaviate.scheme: scheme must be http or https",
+				"line 10: chats.coordinator.availableFunctions.1: unknown function",
+				"line 13: chats.waiter.model: model is required",
+				"line 14: chats.waiter.temperature: temperature must be between 0 and 2",
+				"line 15: chats.waiter.tmpHttpPort: port 3000 is already used by chat coordinator",
+				"line 18: functions.get_drink_recipe.url: url scheme must be http or https",
+				"line 20: tools.cocktail_search: unknown built-in tool",
+			},
+		},
+		{
+			name: "collection without embedding model",
+			yaml: `
+weaviate:
+  scheme: http
+  host: localhost:8080
+  collections:
+    Cocktail:
+      vectorizer: none
+chats:
+  bartender:
+    model: "qwen3:1.7b"
+`,
+			errors: []string{"line 7: weaviate.collections.Cocktail.vectorizer: vectorizer none needs an embeddingModel"},
+		},
+		{
+			name: "enums",
+			yaml: `
+store:
+  type: sqlite
+cassette:
+  mode: play
+chats:
+  bartender:
+    model: "qwen3:1.7b"
+    responseFormat:
+      type: json
+      schema:
+        type: object
+tools:
+  cocktail_list:
+    search:
+      mode: vector
+    rerank:
+      method: cross-encoder
+  cocktail_recipe:
+    search:
+      limit: 5
+    rerank:
+      method: llm
+      candidates: 3
+`,
+			errors: []string{
+				`line 3: store.type: type must be one of weaviate, memory, got "sqlite"`,
+				`line 5: cassette.mode: mode must be one of record, replay, got "play"`,
+				`line 10: chats.bartender.responseFormat.type: type must be one of json_schema, json_object, got "json"`,
+				`line 16: tools.cocktail_list.search.mode: mode must be one of near-text, bm25, hybrid, name, got "vector"`,
+				`line 18: tools.cocktail_list.rerank.method: method must be one of llm, keyword, got "cross-encoder"`,
+				"line 24: tools.cocktail_recipe.rerank.candidates: candidates must be at least search limit 5, got 3",
+				"line 23: tools.cocktail_recipe.rerank.method: method llm needs a model",
+			},
+		},
+		{
+			name: "budget and pricing",
+			yaml: `
+store:
+  type: memory
+chats:
+  bartender:
+    model: "qwen3:1.7b"
+    maxToolRounds: -1
+    budget:
+      turnTokens: -1
+pricing:
+  "qwen3:1.7b":
+    input: 0.1
+    output: -0.2
+`,
+			errors: []string{
+				"line 7: chats.bartender.maxToolRounds: maxToolRounds can't be negative",
+				"line 9: chats.bartender.budget.turnTokens: turnTokens can't be negative",
+				"line 11: pricing.qwen3:1.7b: prices can't be negative",
+			},
+		},
+		{
+			name: "log",
+			yaml: `
+store:
+  type: memory
+chats:
+  bartender:
+    model: "qwen3:1.7b"
+log:
+  level: verbose
+`,
+			errors: []string{`line 7: log: unknown log level "verbose"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configPath, []byte(tt.yaml), 0644); err != nil {
+				t.Fatalf("failed to write temp config file: %v", err)
+			}
+
+			_, err := ParseConfig(configPath)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			for _, want := range tt.errors {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected error %q, got:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestParseConfig_ValidationErrors(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("chats: {}\n"), 0644); err != nil {
+		t.Fatalf("failed to write temp config file: %v", err)
+	}
+
+	_, err := ParseConfig(configPath)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors, got %T: %v", err, err)
+	}
+	if len(errs) != 2 || errs[0].Path != "weaviate" || errs[1].Path != "chats" {
+		t.Errorf("unexpected errors: %+v", errs)
+	}
+}
@@ -0,0 +1,244 @@
+package appconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts environment variables overriding config values, path
+// elements are separated by "__" and matched case-insensitively, e.g.
+// APP_WEAVIATE__HOST or APP_CHATS__WAITER__TMPHTTPPORT. Variables not
+// starting with a config section name are ignored.
+const EnvPrefix = "APP_"
+
+// Secret is a config value kept out of logs and dumps, Value returns it.
+type Secret string
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[REDACTED]"
+}
+
+func (s Secret) GoString() string {
+	return strconv.Quote(s.String())
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+// envRef is ${NAME} or ${NAME:-default}, $${ is a literal ${
+var envRef = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// interpolate replaces environment variable references in scalar values of
+// a parsed file, comments and keys are left as they are. A plain (unquoted)
+// value is parsed again, so it can become a number or a list; a quoted one
+// stays a string. A variable without a default must be set.
+func interpolate(node *yaml.Node) error {
+	var errs ValidationErrors
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		switch node.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range node.Content {
+				walk(child)
+			}
+		case yaml.MappingNode:
+			for i := 1; i < len(node.Content); i += 2 {
+				walk(node.Content[i])
+			}
+		case yaml.ScalarNode:
+			if !strings.Contains(node.Value, "${") {
+				return
+			}
+			value := envRef.ReplaceAllStringFunc(node.Value, func(ref string) string {
+				if strings.HasPrefix(ref, "$$") {
+					return ref[1:]
+				}
+				m := envRef.FindStringSubmatch(ref)
+				name := m[1]
+				hasDefault := strings.HasPrefix(m[2], ":-")
+				if value, ok := os.LookupEnv(name); ok && (value != "" || !hasDefault) {
+					return value
+				}
+				if hasDefault {
+					return m[3]
+				}
+				errs = append(errs, ValidationError{
+					Line:    node.Line,
+					Path:    "${" + name + "}",
+					Message: fmt.Sprintf("environment variable %s is not set, use ${%s:-} for an empty default", name, name),
+				})
+				return ref
+			})
+			setScalar(node, value)
+		}
+	}
+	walk(node)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// setScalar replaces the value of a scalar node, a plain one is parsed as YAML.
+func setScalar(node *yaml.Node, value string) {
+	if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		node.Value = value
+		return
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &doc); err != nil || len(doc.Content) == 0 {
+		*node = yaml.Node{Kind: yaml.ScalarNode, Value: value, Line: node.Line, Column: node.Column}
+		return
+	}
+	line, column := node.Line, node.Column
+	*node = *doc.Content[0]
+	setLine(node, line, column)
+}
+
+// setLine moves a node parsed from a value to the value's position.
+func setLine(node *yaml.Node, line int, column int) {
+	node.Line, node.Column = line, column
+	for _, child := range node.Content {
+		setLine(child, line, column)
+	}
+}
+
+// applyEnvOverrides sets config values from EnvPrefix variables in environ
+// ("NAME=value" as os.Environ), creating missing parts of the path. Values are
+// YAML, so lists can be given as [a, b].
+func applyEnvOverrides(root *yaml.Node, environ []string) error {
+	names := []string{}
+	values := map[string]string{}
+	for _, env := range environ {
+		name, value, _ := strings.Cut(env, "=")
+		if strings.HasPrefix(name, EnvPrefix) && len(name) > len(EnvPrefix) {
+			names = append(names, name)
+			values[name] = value
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	if root.Kind == 0 {
+		*root = yaml.Node{Kind: yaml.DocumentNode, Line: 1}
+	}
+	if len(root.Content) == 0 {
+		root.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map", Line: 1}}
+	}
+
+	for _, name := range names {
+		path := strings.Split(strings.TrimPrefix(name, EnvPrefix), "__")
+		if _, ok := yamlField(reflect.TypeOf(AppConfig{}), path[0]); !ok {
+			// not meant for the config, e.g. APP_UID of the docker build
+			logger().Debug("ignored variable, not a config section", "variable", name, "section", strings.ToLower(path[0]))
+			continue
+		}
+		node, err := envNode(root.Content[0], reflect.TypeOf(AppConfig{}), path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		var value yaml.Node
+		if err := yaml.Unmarshal([]byte(values[name]), &value); err != nil {
+			return fmt.Errorf("%s: invalid value: %w", name, err)
+		}
+		line := node.Line
+		if len(value.Content) > 0 {
+			*node = *value.Content[0]
+		} else {
+			*node = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str"}
+		}
+		node.Line = line
+	}
+	return nil
+}
+
+// envNode finds the node at path, path elements are matched against yaml
+// tags of typ fields and map keys.
+func envNode(node *yaml.Node, typ reflect.Type, path []string) (*yaml.Node, error) {
+	for i, elem := range path {
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+
+		var key string
+		switch typ.Kind() {
+		case reflect.Struct:
+			field, ok := yamlField(typ, elem)
+			if !ok {
+				return nil, fmt.Errorf("unknown config field %s", strings.ToLower(elem))
+			}
+			key, typ = field.Tag.Get("yaml"), field.Type
+		case reflect.Map:
+			key, typ = strings.ToLower(elem), typ.Elem()
+			if node.Kind == yaml.MappingNode {
+				for j := 0; j < len(node.Content); j += 2 {
+					if strings.EqualFold(node.Content[j].Value, elem) {
+						key = node.Content[j].Value
+						break
+					}
+				}
+			}
+		case reflect.Slice:
+			n, err := strconv.Atoi(elem)
+			if err != nil || node.Kind != yaml.SequenceNode || n < 0 || n >= len(node.Content) {
+				return nil, fmt.Errorf("no list item %s", elem)
+			}
+			node, typ = node.Content[n], typ.Elem()
+			continue
+		default:
+			return nil, fmt.Errorf("%s is not a field of %s", strings.ToLower(elem), strings.ToLower(strings.Join(path[:i], ".")))
+		}
+
+		if node.Kind != yaml.MappingNode {
+			// e.g. "weaviate:" without value
+			*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: node.Line}
+		}
+		var next *yaml.Node
+		for j := 0; j < len(node.Content); j += 2 {
+			if node.Content[j].Value == key {
+				next = node.Content[j+1]
+				break
+			}
+		}
+		if next == nil {
+			next = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Line: node.Line}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key, Line: node.Line}, next)
+		}
+		node = next
+	}
+	return node, nil
+}
+
+func yamlField(typ reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("yaml")
+		if tag != "" && tag != "-" && strings.EqualFold(strings.ReplaceAll(tag, "_", ""), strings.ReplaceAll(name, "_", "")) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
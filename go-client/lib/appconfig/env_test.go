@@ -0,0 +1,180 @@
+package appconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write temp config file: %v", err)
+	}
+	return path
+}
+
+func TestParseConfig_Interpolation(t *testing.T) {
+	t.Setenv("TEST_MODEL", "qwen3:1.7b")
+	t.Setenv("TEST_EMPTY", "")
+	t.Setenv("TEST_FUNCTIONS", "[get_current_time, cocktail_list]")
+
+	path := writeConfig(t, `
+# ${TEST_NOT_SET} in a comment is left alone
+weaviate:
+  scheme: ${TEST_SCHEME:-http} # also after a value: ${TEST_NOT_SET}
+  host: "${TEST_HOST:-localhost:8080}"
+chats:
+  coordinator:
+    model: ${TEST_MODEL}
+    temperature: ${TEST_EMPTY:-0.5}
+    availableFunctions: ${TEST_FUNCTIONS}
+    prompt:
+      role: "costs $${PRICE}"
+`)
+	cfg, err := ParseConfig(path)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if cfg.Weaviate.Scheme != "http" || cfg.Weaviate.Host != "localhost:8080" {
+		t.Errorf("unexpected weaviate config: %+v", cfg.Weaviate)
+	}
+	chat := cfg.AiChatCfg["coordinator"]
+	if chat.Model != "qwen3:1.7b" || chat.Temperature != 0.5 || len(chat.AvailableFunctions) != 2 {
+		t.Errorf("unexpected chat config: %+v", chat)
+	}
+	if chat.Prompt.Role.Text != "costs ${PRICE}" {
+		t.Errorf("expected escaped reference, got %q", chat.Prompt.Role.Text)
+	}
+
+	path = writeConfig(t, "weaviate:\n  scheme: http\n  host: ${TEST_NOT_SET}\n")
+	_, err = ParseConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "line 3: ${TEST_NOT_SET}: environment variable TEST_NOT_SET is not set") {
+		t.Errorf("expected unset variable error, got: %v", err)
+	}
+}
+
+func TestParseConfig_EnvOverrides(t *testing.T) {
+	t.Setenv("APP_WEAVIATE__HOST", "weaviate:8080")
+	t.Setenv("APP_CHATS__Coordinator__TMPHTTPPORT", "3005")
+	t.Setenv("APP_CHATS__COORDINATOR__AVAILABLEFUNCTIONS", "[get_current_time, cocktail_list]")
+	t.Setenv("APP_CHATS__WAITER__MODEL", "llama3.2")
+	t.Setenv("APP_OPENAI__TOKEN", "sk-override")
+	t.Setenv("APP_UID", "1000")
+
+	path := writeConfig(t, `
+weaviate:
+  scheme: http
+  host: localhost:8080
+chats:
+  coordinator:
+    model: "qwen3:1.7b"
+    tmpHttpPort: 3000
+`)
+	cfg, err := ParseConfig(path)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if cfg.Weaviate.Host != "weaviate:8080" {
+		t.Errorf("expected overridden host, got %q", cfg.Weaviate.Host)
+	}
+	coordinator := cfg.AiChatCfg["coordinator"]
+	if coordinator.TmpHttpPort != 3005 || len(coordinator.AvailableFunctions) != 2 {
+		t.Errorf("unexpected coordinator config: %+v", coordinator)
+	}
+	if waiter := cfg.AiChatCfg["waiter"]; waiter == nil || waiter.Model != "llama3.2" {
+		t.Errorf("expected waiter chat created from env, got %+v", waiter)
+	}
+	if cfg.OpenAI == nil || cfg.OpenAI.Token.Value() != "sk-override" {
+		t.Errorf("expected overridden token, got %+v", cfg.OpenAI)
+	}
+
+	t.Setenv("APP_WEAVIATE__PORT", "8080")
+	if _, err := ParseConfig(path); err == nil || !strings.Contains(err.Error(), "APP_WEAVIATE__PORT: unknown config field port") {
+		t.Errorf("expected unknown field error, got: %v", err)
+	}
+}
+
+func TestParseConfig_TokenFile(t *testing.T) {
+	t.Setenv("OPENAI_API_TOKEN", "sk-env")
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("sk-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := writeConfig(t, fmt.Sprintf(`
+store:
+  type: memory
+chats:
+  coordinator:
+    model: "qwen3:1.7b"
+openai:
+  url: http://ollama:11434/v1
+  token_file: %s
+`, tokenFile))
+	cfg, err := ParseConfig(path)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	api := cfg.OpenAIAPI()
+	if api.URL != "http://ollama:11434/v1" || api.Token.Value() != "sk-secret" {
+		t.Errorf("unexpected api config: %+v", api)
+	}
+
+	dumps := []string{fmt.Sprintf("%v %+v %#v %s", api, api, api, api.Token)}
+	data, _ := json.Marshal(cfg)
+	dumps = append(dumps, string(data))
+	for _, dump := range dumps {
+		if strings.Contains(dump, "sk-secret") {
+			t.Errorf("secret leaked: %s", dump)
+		}
+		if !strings.Contains(dump, "[REDACTED]") {
+			t.Errorf("expected redacted secret: %s", dump)
+		}
+	}
+
+	// values missing in config come from the environment
+	if api := (&AppConfig{}).OpenAIAPI(); api.Token.Value() != "sk-env" {
+		t.Errorf("expected token from OPENAI_API_TOKEN, got %q", api.Token.Value())
+	}
+}
+
+func TestParseConfig_TokenFileRelativeToConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "secrets", "keys"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"config.yaml": `
+include: [secrets/openai.yaml]
+store:
+  type: memory
+chats:
+  coordinator:
+    model: "qwen3:1.7b"
+`,
+		"secrets/openai.yaml": `
+openai:
+  token_file: keys/token
+`,
+		"secrets/keys/token": "sk-included\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := ParseConfig(filepath.Join(tmpDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if token := cfg.OpenAIAPI().Token.Value(); token != "sk-included" {
+		t.Errorf("expected token read relative to the included file, got %q", token)
+	}
+}
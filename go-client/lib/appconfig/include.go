@@ -0,0 +1,303 @@
+package appconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFile is a parsed config file, root is its top mapping, nil if empty.
+type configFile struct {
+	path string
+	root *yaml.Node
+}
+
+// readConfigFiles reads a config file and files it includes, depth first in
+// include order. Include patterns are relative to the including file, glob
+// matches are sorted. A file is read once, even if included again. It also
+// returns all include patterns, to notice files added later.
+func readConfigFiles(path string) ([]configFile, []string, error) {
+	var files []configFile
+	var globs []string
+	seen := map[string]bool{}
+
+	var read func(path string) error
+	read = func(path string) error {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if seen[abs] {
+			return nil
+		}
+		seen[abs] = true
+
+		file, include, err := readConfigFile(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+
+		for _, pattern := range include {
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(filepath.Dir(path), pattern)
+			}
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid include %q: %w", path, pattern, err)
+			}
+			if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+				return fmt.Errorf("%s: included file %s not found", path, pattern)
+			}
+			globs = append(globs, pattern)
+			sort.Strings(matches)
+			for _, match := range matches {
+				if err := read(match); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := read(path); err != nil {
+		return nil, nil, err
+	}
+	return files, globs, nil
+}
+
+// readConfigFile parses one file with environment references replaced and
+// checks its fields, the include list is removed from the tree and returned.
+func readConfigFile(path string) (configFile, []string, error) {
+	file := configFile{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return file, nil, fmt.Errorf("cannot read file %s: %w", path, err)
+	}
+	if err := checkFields(data); err != nil {
+		return file, nil, fmt.Errorf("YAML parsing error in %s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return file, nil, fmt.Errorf("YAML parsing error in %s: %w", path, err)
+	}
+	if err := interpolate(&doc); err != nil {
+		return file, nil, withFile(err, path)
+	}
+	if len(doc.Content) == 0 {
+		return file, nil, nil
+	}
+
+	file.root = doc.Content[0]
+	var include []string
+	if node := mappingValue(file.root, "include"); node != nil {
+		if err := node.Decode(&include); err != nil {
+			return file, nil, fmt.Errorf("YAML parsing error in %s: %w", path, err)
+		}
+	}
+	if file.root.Kind == yaml.MappingNode {
+		for i := 0; i < len(file.root.Content); i += 2 {
+			if file.root.Content[i].Value == "include" {
+				file.root.Content = append(file.root.Content[:i], file.root.Content[i+2:]...)
+				break
+			}
+		}
+	}
+	resolvePromptFiles(file.root, filepath.Dir(path))
+	resolveSecretFiles(file.root, filepath.Dir(path))
+	return file, include, nil
+}
+
+// checkFields strictly decodes a file, so typos of field names point to its
+// lines. Values still have environment references, so only unknown and
+// repeated fields are errors here, values are checked on the merged config.
+func checkFields(data []byte) error {
+	var cfg AppConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err := dec.Decode(&cfg)
+	if err == nil || err == io.EOF {
+		return nil
+	}
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+	fieldErrs := &yaml.TypeError{}
+	for _, e := range typeErr.Errors {
+		if strings.Contains(e, " not found in type ") || strings.Contains(e, " already set in type ") {
+			fieldErrs.Errors = append(fieldErrs.Errors, e)
+		}
+	}
+	if len(fieldErrs.Errors) == 0 {
+		return nil
+	}
+	return fieldErrs
+}
+
+// resolvePromptFiles makes relative prompt section files of chats relative
+// to dir, the directory of the file declaring them.
+func resolvePromptFiles(root *yaml.Node, dir string) {
+	for _, chat := range mappingValues(mappingValue(root, "chats")) {
+		prompt := mappingValue(chat, "prompt")
+		sections := []*yaml.Node{}
+		for _, name := range []string{"role", "context", "examples", "task", "instructions"} {
+			sections = append(sections, mappingValue(prompt, name))
+		}
+		if custom := mappingValue(prompt, "sections"); custom != nil && custom.Kind == yaml.SequenceNode {
+			sections = append(sections, custom.Content...)
+		}
+		for _, section := range sections {
+			file := mappingValue(section, "file")
+			if file != nil && file.Kind == yaml.ScalarNode && file.Value != "" && !filepath.IsAbs(file.Value) {
+				file.Value = filepath.Join(dir, file.Value)
+			}
+		}
+	}
+}
+
+// resolveSecretFiles makes a relative openai.token_file relative to dir,
+// the directory of the file declaring it.
+func resolveSecretFiles(root *yaml.Node, dir string) {
+	file := mappingValue(mappingValue(root, "openai"), "token_file")
+	if file != nil && file.Kind == yaml.ScalarNode && file.Value != "" && !filepath.IsAbs(file.Value) {
+		file.Value = filepath.Join(dir, file.Value)
+	}
+}
+
+// mappingValue returns the value of key in a mapping node, nil if missing.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// mappingValues returns values of a mapping node.
+func mappingValues(node *yaml.Node) []*yaml.Node {
+	var values []*yaml.Node
+	if node == nil || node.Kind != yaml.MappingNode {
+		return values
+	}
+	for i := 1; i < len(node.Content); i += 2 {
+		values = append(values, node.Content[i])
+	}
+	return values
+}
+
+// withFile sets the file of validation errors.
+func withFile(err error, path string) error {
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		return err
+	}
+	for i := range errs {
+		errs[i].File = path
+	}
+	return errs
+}
+
+// mergeConfigFiles merges file trees into one document: maps are merged
+// recursively, other values defined in more than one file must be equal.
+// sources maps every node to the file it comes from.
+func mergeConfigFiles(files []configFile) (*yaml.Node, map[*yaml.Node]string, error) {
+	sources := map[*yaml.Node]string{}
+	var markSource func(node *yaml.Node, path string)
+	markSource = func(node *yaml.Node, path string) {
+		sources[node] = path
+		for _, child := range node.Content {
+			markSource(child, path)
+		}
+	}
+
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Line: 1}
+	var errs ValidationErrors
+	for _, file := range files {
+		if file.root == nil || isNull(file.root) {
+			continue
+		}
+		markSource(file.root, file.path)
+		if len(doc.Content) == 0 {
+			doc.Content = []*yaml.Node{file.root}
+			continue
+		}
+		errs = append(errs, mergeNodes(doc.Content[0], file.root, nil, sources)...)
+	}
+	if len(errs) > 0 {
+		return nil, nil, errs
+	}
+	if len(doc.Content) == 0 {
+		doc = &yaml.Node{}
+	}
+	return doc, sources, nil
+}
+
+func mergeNodes(dst *yaml.Node, src *yaml.Node, path []string, sources map[*yaml.Node]string) ValidationErrors {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return ValidationErrors{conflict(dst, src, path, sources)}
+	}
+
+	var errs ValidationErrors
+	for i := 0; i < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		found := false
+		for j := 0; j < len(dst.Content); j += 2 {
+			if dst.Content[j].Value != key.Value {
+				continue
+			}
+			found = true
+			current := dst.Content[j+1]
+			keyPath := append(append([]string{}, path...), key.Value)
+			switch {
+			case isNull(value):
+			case isNull(current):
+				dst.Content[j+1] = value
+			case current.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+				errs = append(errs, mergeNodes(current, value, keyPath, sources)...)
+			case !sameValue(current, value):
+				errs = append(errs, conflict(current, value, keyPath, sources))
+			}
+			break
+		}
+		if !found {
+			dst.Content = append(dst.Content, key, value)
+		}
+	}
+	return errs
+}
+
+func conflict(current *yaml.Node, value *yaml.Node, path []string, sources map[*yaml.Node]string) ValidationError {
+	return ValidationError{
+		File:    sources[value],
+		Line:    value.Line,
+		Path:    strings.Join(path, "."),
+		Message: fmt.Sprintf("conflicts with %s line %d", sources[current], current.Line),
+	}
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null"
+}
+
+func sameValue(a *yaml.Node, b *yaml.Node) bool {
+	var va, vb interface{}
+	if a.Decode(&va) != nil || b.Decode(&vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,163 @@
+package appconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	return dir
+}
+
+func TestParseConfig_Include(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml": `
+include:
+  - agents/*.yaml
+  - functions/*.yaml
+store:
+  type: memory
+chats:
+  coordinator:
+    model: "qwen3:1.7b"
+    availableFunctions: [get_drink_recipe]
+`,
+		"agents/waiter.yaml": `
+chats:
+  waiter:
+    model: "qwen3:1.7b"
+    tmpHttpPort: 3002
+`,
+		"agents/bartender.yaml": `
+include: [../functions/recipe.yaml]
+chats:
+  bartender:
+    model: "llama3.2"
+  coordinator:
+    temperature: 0.5
+`,
+		"functions/recipe.yaml": `
+functions:
+  get_drink_recipe:
+    url: "http://localhost:3002/api/ask"
+`,
+	})
+
+	cfg, err := ParseConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(cfg.AiChatCfg) != 3 || cfg.AiChatCfg["bartender"].Model != "llama3.2" {
+		t.Errorf("unexpected chats: %+v", cfg.AiChatCfg)
+	}
+	coordinator := cfg.AiChatCfg["coordinator"]
+	if coordinator.Model != "qwen3:1.7b" || coordinator.Temperature != 0.5 {
+		t.Errorf("expected coordinator merged from two files, got: %+v", coordinator)
+	}
+	if cfg.FunctionCfg["get_drink_recipe"] == nil {
+		t.Error("expected function from included file")
+	}
+
+	// glob matches are read in sorted order, a file only once
+	want := []string{"config.yaml", "agents/bartender.yaml", "functions/recipe.yaml", "agents/waiter.yaml"}
+	if len(cfg.Files()) != len(want) {
+		t.Fatalf("unexpected files: %v", cfg.Files())
+	}
+	for i, name := range want {
+		if cfg.Files()[i] != filepath.Join(dir, name) {
+			t.Errorf("file %d: expected %s, got %s", i, name, cfg.Files()[i])
+		}
+	}
+}
+
+func TestParseConfig_IncludeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		errors []string
+	}{
+		{
+			name: "conflict",
+			files: map[string]string{
+				"config.yaml":   "include: [agents/*.yaml]\nstore:\n  type: memory\n",
+				"agents/a.yaml": "chats:\n  waiter:\n    model: qwen3:1.7b\n",
+				"agents/b.yaml": "chats:\n  waiter:\n    model: llama3.2\n    tmpHttpPort: 3002\n",
+			},
+			errors: []string{"agents/b.yaml: line 3: chats.waiter.model: conflicts with %DIR%/agents/a.yaml line 3"},
+		},
+		{
+			name: "missing file",
+			files: map[string]string{
+				"config.yaml": "include: [waiter.yaml]\n",
+			},
+			errors: []string{"included file %DIR%/waiter.yaml not found"},
+		},
+		{
+			name: "unknown field in included file",
+			files: map[string]string{
+				"config.yaml": "include: [waiter.yaml]\n",
+				"waiter.yaml": "chats:\n  waiter:\n    modle: qwen3:1.7b\n",
+			},
+			errors: []string{"YAML parsing error in %DIR%/waiter.yaml", "line 3: field modle not found"},
+		},
+		{
+			name: "validation error in included file",
+			files: map[string]string{
+				"config.yaml": "include: [waiter.yaml]\nstore:\n  type: memory\n",
+				"waiter.yaml": "chats:\n  waiter:\n    model: qwen3:1.7b\n    temperature: 3\n",
+			},
+			errors: []string{"%DIR%/waiter.yaml: line 4: chats.waiter.temperature"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			_, err := ParseConfig(filepath.Join(dir, "config.yaml"))
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			for _, want := range tt.errors {
+				want = strings.ReplaceAll(want, "%DIR%", dir)
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected error %q, got:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestWatcher_IncludedFileAdded(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml":        "include: [agents/*.yaml]\nstore:\n  type: memory\n",
+		"agents/waiter.yaml": "chats:\n  waiter:\n    model: qwen3:1.7b\n",
+	})
+	if err := LoadConfig(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	w := NewWatcher(filepath.Join(dir, "config.yaml"))
+
+	path := filepath.Join(dir, "agents", "bartender.yaml")
+	if err := os.WriteFile(path, []byte("chats:\n  bartender:\n    model: llama3.2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := w.Check(); !changed || err != nil {
+		t.Fatalf("expected reload, got %v, %v", changed, err)
+	}
+	if Current().AiChatCfg["bartender"] == nil {
+		t.Error("expected chat from the added file")
+	}
+}
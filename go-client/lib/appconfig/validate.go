@@ -0,0 +1,326 @@
+package appconfig
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError is a config problem at a line of File, 0 if the line is unknown.
+type ValidationError struct {
+	File    string
+	Line    int
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	pos := ""
+	if e.File != "" {
+		pos = e.File + ": "
+	}
+	if e.Line > 0 {
+		pos += fmt.Sprintf("line %d: ", e.Line)
+	}
+	return fmt.Sprintf("%s%s: %s", pos, e.Path, e.Message)
+}
+
+// ValidationErrors are all problems found in a config, ordered by file and line.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// builtinFunctions are functions implemented by the app, registered by the
+// package implementing them, e.g. aiclient
+var builtinFunctions = map[string]bool{}
+
+// RegisterBuiltinFunctions makes function names valid in availableFunctions and tools.
+func RegisterBuiltinFunctions(names ...string) {
+	for _, name := range names {
+		builtinFunctions[name] = true
+	}
+}
+
+// checks validate config parts handled by other packages, e.g. prompt templates
+var checks []func(c *AppConfig) ValidationErrors
+
+// RegisterCheck adds a validation run for every loaded config. Errors
+// without Line get the line of their dot separated Path.
+func RegisterCheck(fn func(c *AppConfig) ValidationErrors) {
+	checks = append(checks, fn)
+}
+
+type validator struct {
+	root    *yaml.Node
+	sources map[*yaml.Node]string
+	file    string // for nodes without source, e.g. set from environment
+	errors  ValidationErrors
+}
+
+// addf records a problem at the config path, e.g. "chats", "waiter", "temperature".
+// Path elements may contain dots, e.g. model names under "pricing".
+func (v *validator) addf(path []string, format string, args ...interface{}) {
+	err := ValidationError{Path: strings.Join(path, "."), Message: fmt.Sprintf(format, args...)}
+	if node := nodeAt(v.root, path); node != nil {
+		err.Line = node.Line
+		err.File = v.sources[node]
+	}
+	v.add(err)
+}
+
+// oneOf records a problem when value is set and isn't one of allowed.
+func (v *validator) oneOf(path []string, value string, allowed ...string) {
+	if value == "" || slices.Contains(allowed, value) {
+		return
+	}
+	v.addf(path, "%s must be one of %s, got %q", path[len(path)-1], strings.Join(allowed, ", "), value)
+}
+
+// add records a problem, its position is found by path if not set.
+func (v *validator) add(err ValidationError) {
+	if err.Line == 0 {
+		if node := nodeAt(v.root, strings.Split(err.Path, ".")); node != nil {
+			err.Line = node.Line
+			err.File = v.sources[node]
+		}
+	}
+	if err.File == "" {
+		err.File = v.file
+	}
+	v.errors = append(v.errors, err)
+}
+
+// Validate checks references between config parts and value ranges.
+// root is the parsed YAML document, used for line numbers.
+func (c *AppConfig) Validate(root *yaml.Node) ValidationErrors {
+	v := &validator{root: root, sources: c.sources}
+	if len(c.files) > 0 {
+		v.file = c.files[0]
+	}
+
+	if c.Weaviate == nil {
+		if c.Store == nil || c.Store.Type != "memory" {
+			v.addf([]string{"weaviate"}, "missing weaviate block, required unless store.type is memory")
+		}
+	} else {
+		if c.Weaviate.Host == "" {
+			v.addf([]string{"weaviate", "host"}, "host is required")
+		}
+		if c.Weaviate.Scheme != "http" && c.Weaviate.Scheme != "https" {
+			v.addf([]string{"weaviate", "scheme"}, "scheme must be http or https, got %q", c.Weaviate.Scheme)
+		}
+		for _, name := range sortedKeys(c.Weaviate.Collections) {
+			if coll := c.Weaviate.Collections[name]; coll != nil && coll.Vectorizer == "none" && coll.EmbeddingModel == "" {
+				v.addf([]string{"weaviate", "collections", name, "vectorizer"}, "vectorizer none needs an embeddingModel")
+			}
+		}
+	}
+
+	if c.Store != nil {
+		v.oneOf([]string{"store", "type"}, c.Store.Type, "weaviate", "memory")
+	}
+	if c.Cassette != nil {
+		v.oneOf([]string{"cassette", "mode"}, c.Cassette.Mode, "record", "replay")
+	}
+
+	if c.OpenAI != nil && c.OpenAI.URL != "" {
+		if err := checkURL(c.OpenAI.URL); err != nil {
+			v.addf([]string{"openai", "url"}, "%v", err)
+		}
+	}
+
+	if len(c.AiChatCfg) == 0 {
+		v.addf([]string{"chats"}, "no chats configured")
+	}
+	ports := map[int]string{}
+	for _, name := range sortedKeys(c.AiChatCfg) {
+		chat := c.AiChatCfg[name]
+		path := []string{"chats", name}
+		if chat == nil {
+			v.addf(path, "empty chat config")
+			continue
+		}
+
+		if chat.Model == "" {
+			v.addf(append(path, "model"), "model is required")
+		}
+		if chat.Temperature < 0 || chat.Temperature > 2 {
+			v.addf(append(path, "temperature"), "temperature must be between 0 and 2, got %g", chat.Temperature)
+		}
+		if chat.TmpHttpPort != 0 {
+			if chat.TmpHttpPort < 1 || chat.TmpHttpPort > 65535 {
+				v.addf(append(path, "tmpHttpPort"), "port must be between 1 and 65535, got %d", chat.TmpHttpPort)
+			} else if other, ok := ports[chat.TmpHttpPort]; ok {
+				v.addf(append(path, "tmpHttpPort"), "port %d is already used by chat %s", chat.TmpHttpPort, other)
+			} else {
+				ports[chat.TmpHttpPort] = name
+			}
+		}
+		for i, f := range chat.AvailableFunctions {
+			if !builtinFunctions[f] && c.FunctionCfg[f] == nil {
+				v.addf(append(path, "availableFunctions", strconv.Itoa(i)), "unknown function %q, not built in and not in functions", f)
+			}
+		}
+		if chat.ToolConcurrency < 0 {
+			v.addf(append(path, "toolConcurrency"), "toolConcurrency can't be negative")
+		}
+		if chat.MaxToolRounds < 0 {
+			v.addf(append(path, "maxToolRounds"), "maxToolRounds can't be negative")
+		}
+		if chat.Examples.K < 0 {
+			v.addf(append(path, "examples", "k"), "k can't be negative")
+		}
+		if chat.ResponseFormat != nil {
+			if chat.ResponseFormat.Schema == nil {
+				v.addf(append(path, "responseFormat"), "schema is required")
+			}
+			v.oneOf(append(path, "responseFormat", "type"), chat.ResponseFormat.Type, "json_schema", "json_object")
+		}
+		if chat.Budget.TurnTokens < 0 {
+			v.addf(append(path, "budget", "turnTokens"), "turnTokens can't be negative")
+		}
+		if chat.Budget.SessionTokens < 0 {
+			v.addf(append(path, "budget", "sessionTokens"), "sessionTokens can't be negative")
+		}
+	}
+
+	for _, name := range sortedKeys(c.FunctionCfg) {
+		f := c.FunctionCfg[name]
+		path := []string{"functions", name}
+		if f == nil {
+			v.addf(path, "empty function config")
+			continue
+		}
+		if builtinFunctions[name] {
+			v.addf(path, "function %s is built in, choose another name", name)
+		}
+		if err := checkURL(f.Url); err != nil {
+			v.addf(append(path, "url"), "%v", err)
+		}
+	}
+
+	for _, name := range sortedKeys(c.ToolCfg) {
+		if !builtinFunctions[name] {
+			v.addf([]string{"tools", name}, "unknown built-in tool %q", name)
+			continue
+		}
+		t := c.ToolCfg[name]
+		if t == nil {
+			continue
+		}
+		v.oneOf([]string{"tools", name, "search", "mode"}, t.Search.Mode, "near-text", "bm25", "hybrid", "name")
+		if t.Search.Alpha != nil && (*t.Search.Alpha < 0 || *t.Search.Alpha > 1) {
+			v.addf([]string{"tools", name, "search", "alpha"}, "alpha must be between 0 and 1, got %g", *t.Search.Alpha)
+		}
+		v.oneOf([]string{"tools", name, "rerank", "method"}, t.Rerank.Method, "llm", "keyword")
+		if t.Rerank.Method == "llm" && t.Rerank.Model == "" {
+			v.addf([]string{"tools", name, "rerank", "method"}, "method llm needs a model")
+		}
+		if t.Rerank.Candidates < 0 {
+			v.addf([]string{"tools", name, "rerank", "candidates"}, "candidates can't be negative")
+		} else if t.Rerank.Candidates > 0 && t.Rerank.Candidates < t.Search.Limit {
+			v.addf([]string{"tools", name, "rerank", "candidates"}, "candidates must be at least search limit %d, got %d", t.Search.Limit, t.Rerank.Candidates)
+		}
+	}
+
+	if c.Log != nil {
+		if err := c.Log.Validate(); err != nil {
+			v.addf([]string{"log"}, "%v", err)
+		}
+	}
+
+	for _, model := range sortedKeys(c.Pricing) {
+		if p := c.Pricing[model]; p.Input < 0 || p.Output < 0 {
+			v.addf([]string{"pricing", model}, "prices can't be negative")
+		}
+	}
+
+	for _, check := range checks {
+		for _, err := range check(c) {
+			v.add(err)
+		}
+	}
+
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		if v.errors[i].File != v.errors[j].File {
+			return v.errors[i].File < v.errors[j].File
+		}
+		return v.errors[i].Line < v.errors[j].Line
+	})
+	return v.errors
+}
+
+func checkURL(s string) error {
+	if s == "" {
+		return fmt.Errorf("url is required")
+	}
+	u, err := url.ParseRequestURI(s)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url scheme must be http or https, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("url %q has no host", s)
+	}
+	return nil
+}
+
+// nodeAt returns the node of path, or of its closest existing parent: the key
+// node for mapping values, values of blocks start on the line after the key.
+// Path elements are mapping keys or sequence indexes.
+func nodeAt(root *yaml.Node, path []string) *yaml.Node {
+	if root == nil || root.Kind == 0 {
+		return nil
+	}
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	found := node
+	for _, key := range path {
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					found = node.Content[i]
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(node.Content) {
+				next = node.Content[i]
+				found = next
+			}
+		}
+		if next == nil {
+			return found
+		}
+		node = next
+	}
+	return found
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,131 @@
+package appconfig
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultWatchInterval is how often a Watcher checks files.
+const DefaultWatchInterval = 2 * time.Second
+
+// Watcher reloads the config when the config file, included files or prompt
+// files change, or a file matching an include pattern is added.
+// A valid config replaces the running one, sessions already started keep
+// theirs until reloaded. An invalid config is logged and ignored.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	onReload []func(cfg *AppConfig)
+	hashes   map[string]string
+	globs    []string
+}
+
+// NewWatcher watches files of the running config loaded from path.
+func NewWatcher(path string) *Watcher {
+	w := &Watcher{path: path, interval: DefaultWatchInterval}
+	files := []string{path}
+	if cfg := Current(); cfg != nil && len(cfg.Files()) > 0 {
+		files = cfg.Files()
+		w.globs = cfg.globs
+	}
+	w.hashes = fileHashes(files)
+	return w
+}
+
+// WithInterval sets how often files are checked.
+func (w *Watcher) WithInterval(interval time.Duration) *Watcher {
+	w.interval = interval
+	return w
+}
+
+// OnReload adds a function called with every new config.
+func (w *Watcher) OnReload(fn func(cfg *AppConfig)) *Watcher {
+	w.onReload = append(w.onReload, fn)
+	return w
+}
+
+// Run checks files every interval until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	logger().Info("watching config", "file", w.path)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Check()
+		}
+	}
+}
+
+// Check reloads the config if a watched file changed, it tells whether
+// a new config was set. A version is loaded once, a rejected one again
+// only after the next change.
+func (w *Watcher) Check() (bool, error) {
+	files := make([]string, 0, len(w.hashes))
+	for file := range w.hashes {
+		files = append(files, file)
+	}
+	for _, pattern := range w.globs {
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			if _, ok := w.hashes[match]; !ok {
+				files = append(files, match)
+			}
+		}
+	}
+	hashes := fileHashes(files)
+	if sameHashes(hashes, w.hashes) {
+		return false, nil
+	}
+	w.hashes = hashes
+
+	logger().Info("config changed, reloading", "file", w.path)
+	cfg, err := loadConfig(w.path)
+	if err != nil {
+		logger().Warn("config reload rejected, running config kept", "err", err)
+		return false, err
+	}
+
+	Set(cfg)
+	// included and prompt files may be added or removed
+	w.hashes = fileHashes(cfg.Files())
+	w.globs = cfg.globs
+	for _, fn := range w.onReload {
+		fn(cfg)
+	}
+	logger().Info("config reloaded")
+	return true, nil
+}
+
+// fileHashes returns content hashes of files, empty for missing ones.
+func fileHashes(files []string) map[string]string {
+	hashes := make(map[string]string, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			hashes[file] = ""
+			continue
+		}
+		sum := sha256.Sum256(data)
+		hashes[file] = hex.EncodeToString(sum[:])
+	}
+	return hashes
+}
+
+func sameHashes(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for file, hash := range a {
+		if h, ok := b[file]; !ok || h != hash {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package appconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWatcher_Check(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	promptPath := filepath.Join(dir, "role.md")
+	write := func(path string, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	config := func(model string) string {
+		return `
+store:
+  type: memory
+chats:
+  coordinator:
+    model: "` + model + `"
+    prompt:
+      role:
+        file: ` + promptPath + `
+`
+	}
+
+	write(promptPath, "You are a bartender")
+	write(configPath, config("qwen3:1.7b"))
+	if err := LoadConfig(configPath); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	first := Current()
+
+	reloads := 0
+	w := NewWatcher(configPath).OnReload(func(cfg *AppConfig) { reloads++ })
+	if changed, err := w.Check(); changed || err != nil {
+		t.Fatalf("expected no change, got %v, %v", changed, err)
+	}
+
+	write(configPath, config("llama3.2"))
+	if changed, err := w.Check(); !changed || err != nil {
+		t.Fatalf("expected reload, got %v, %v", changed, err)
+	}
+	second := Current()
+	if second.AiChatCfg["coordinator"].Model != "llama3.2" || reloads != 1 {
+		t.Errorf("unexpected config after reload: %+v, %d reloads", second.AiChatCfg["coordinator"], reloads)
+	}
+	if first.AiChatCfg["coordinator"].Model != "qwen3:1.7b" {
+		t.Error("previous config was modified")
+	}
+
+	// invalid version is rejected once, the running config stays
+	write(configPath, config("llama3.2")+"    temperature: 5\n")
+	if changed, err := w.Check(); changed || err == nil {
+		t.Fatalf("expected rejected reload, got %v, %v", changed, err)
+	}
+	if changed, err := w.Check(); changed || err != nil {
+		t.Fatalf("expected rejected version not loaded again, got %v, %v", changed, err)
+	}
+	if Current() != second {
+		t.Error("running config replaced by an invalid one")
+	}
+
+	// prompt files are watched too
+	write(configPath, config("llama3.2"))
+	w.Check()
+	write(promptPath, "You are a waiter")
+	if changed, err := w.Check(); !changed || err != nil {
+		t.Fatalf("expected reload after prompt change, got %v, %v", changed, err)
+	}
+	if reloads != 3 {
+		t.Errorf("expected 3 reloads, got %d", reloads)
+	}
+}
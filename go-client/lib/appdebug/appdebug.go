@@ -2,14 +2,15 @@ package appdebug
 
 import (
 	"encoding/json"
-	"fmt"
+	"go-client/lib/applog"
 )
 
+// PrettyPrint logs p as indented JSON at debug level.
 func PrettyPrint(p interface{}) {
 	b, err := json.MarshalIndent(p, "", "  ")
 	if err != nil {
-		fmt.Println("error:", err)
+		applog.Component("debug").Error("pretty print failed", "err", err)
 		return
 	}
-	fmt.Println(string(b))
+	applog.Component("debug").Debug("pretty print", "value", string(b))
 }
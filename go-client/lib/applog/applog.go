@@ -0,0 +1,239 @@
+// Package applog sets up structured logging with log/slog: level and format
+// from config, levels per component, attributes carried by a context
+// (session, chat, tool, trace ID) and redaction of secrets and PII.
+package applog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+)
+
+// Attribute keys used across the app.
+const (
+	ComponentKey = "component"
+	SessionKey   = "session"
+	ChatKey      = "chat"
+	ToolKey      = "tool"
+	TraceIDKey   = "trace_id"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Config is the "log" config block. Components sets levels of single
+// components, e.g. aiclient: debug. Redact lists more regexps of text
+// masked in all log lines.
+type Config struct {
+	Level      string            `yaml:"level"`  // debug, info (default), warn, error
+	Format     string            `yaml:"format"` // text (default), json
+	Components map[string]string `yaml:"components"`
+	Redact     []string          `yaml:"redact"`
+}
+
+var (
+	mu     sync.Mutex
+	output io.Writer = os.Stderr
+	config Config
+)
+
+// Setup makes cfg the logging config of slog.Default and of the log package,
+// whose remaining calls (log.Fatal) are logged as errors.
+func Setup(cfg Config) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return setup(cfg, output)
+}
+
+// SetOutput writes logs to w with the config of the last Setup.
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	setup(config, w)
+}
+
+func setup(cfg Config, w io.Writer) error {
+	h, err := NewHandler(cfg, w)
+	if err != nil {
+		return err
+	}
+	config = cfg
+	output = w
+	slog.SetDefault(slog.New(h))
+	slog.SetLogLoggerLevel(slog.LevelError)
+	return nil
+}
+
+// NewHandler returns a handler writing to w as configured by cfg.
+func NewHandler(cfg Config, w io.Writer) (slog.Handler, error) {
+	levels, err := cfg.levels()
+	if err != nil {
+		return nil, err
+	}
+	redactor, err := newRedactor(cfg.Redact)
+	if err != nil {
+		return nil, err
+	}
+
+	// levels are checked by handler, per component
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: redactor.replaceAttr}
+	var inner slog.Handler
+	switch cfg.Format {
+	case "", FormatText:
+		inner = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		inner = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected %s or %s", cfg.Format, FormatText, FormatJSON)
+	}
+	return &handler{inner: inner, levels: levels, level: levels.level("")}, nil
+}
+
+// Validate checks levels, format and redaction patterns.
+func (c Config) Validate() error {
+	_, err := NewHandler(c, io.Discard)
+	return err
+}
+
+type levels struct {
+	all        slog.Level
+	components map[string]slog.Level
+}
+
+func (l levels) level(component string) slog.Level {
+	if level, ok := l.components[component]; ok {
+		return level
+	}
+	return l.all
+}
+
+func (c Config) levels() (levels, error) {
+	l := levels{components: map[string]slog.Level{}}
+	var err error
+	if l.all, err = parseLevel(c.Level); err != nil {
+		return levels{}, err
+	}
+	for component, level := range c.Components {
+		if l.components[component], err = parseLevel(level); err != nil {
+			return levels{}, fmt.Errorf("component %s: %w", component, err)
+		}
+	}
+	return l, nil
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// handler filters records by the level of their component and adds
+// attributes of the context not set by WithAttrs already.
+type handler struct {
+	inner  slog.Handler
+	levels levels
+	level  slog.Level // of the component set by WithAttrs
+	keys   []string   // set by WithAttrs
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := contextAttrs(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		for _, a := range attrs {
+			if !slices.Contains(h.keys, a.Key) {
+				r.AddAttrs(a)
+			}
+		}
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.inner = h.inner.WithAttrs(attrs)
+	c.keys = slices.Clone(h.keys)
+	for _, a := range attrs {
+		c.keys = append(c.keys, a.Key)
+		if a.Key == ComponentKey {
+			c.level = h.levels.level(a.Value.String())
+		}
+	}
+	return &c
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.inner = h.inner.WithGroup(name)
+	return &c
+}
+
+// Component returns the default logger of a component, its level can be
+// set apart in config. Loggers aren't kept, so they follow Setup.
+func Component(name string) *slog.Logger {
+	return slog.Default().With(ComponentKey, name)
+}
+
+type ctxKey struct{}
+
+// WithAttrs returns a context whose attributes are added to every line
+// logged with it, e.g. slog.InfoContext(ctx, ...).
+func WithAttrs(ctx context.Context, args ...any) context.Context {
+	r := slog.Record{}
+	r.Add(args...)
+	attrs := append([]slog.Attr{}, contextAttrs(ctx)...)
+	r.Attrs(func(a slog.Attr) bool {
+		// a later value replaces an earlier one, e.g. the tool
+		attrs = replaceAttr(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, ctxKey{}, attrs)
+}
+
+func replaceAttr(attrs []slog.Attr, a slog.Attr) []slog.Attr {
+	for i := range attrs {
+		if attrs[i].Key == a.Key {
+			attrs[i] = a
+			return attrs
+		}
+	}
+	return append(attrs, a)
+}
+
+func contextAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	return attrs
+}
+
+// WithTraceID returns a context logging the trace ID of a request, passed
+// on to agents called as tools.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return WithAttrs(ctx, TraceIDKey, id)
+}
+
+// TraceID returns the trace ID of ctx, empty if there is none.
+func TraceID(ctx context.Context) string {
+	for _, a := range contextAttrs(ctx) {
+		if a.Key == TraceIDKey {
+			return a.Value.String()
+		}
+	}
+	return ""
+}
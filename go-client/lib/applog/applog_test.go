@@ -0,0 +1,102 @@
+package applog
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestHandler_ComponentLevels(t *testing.T) {
+	var out strings.Builder
+	h, err := NewHandler(Config{Level: "warn", Components: map[string]string{"aiclient": "debug"}}, &out)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	logger := slog.New(h)
+
+	logger.Info("hidden")
+	logger.With(ComponentKey, "config").Info("hidden too")
+	logger.With(ComponentKey, "aiclient").Debug("shown")
+	logger.Warn("shown too")
+
+	if got := out.String(); strings.Contains(got, "hidden") || strings.Count(got, "shown") != 2 {
+		t.Errorf("unexpected lines:\n%s", got)
+	}
+}
+
+func TestHandler_ContextAttrsJSON(t *testing.T) {
+	var out strings.Builder
+	h, err := NewHandler(Config{Format: FormatJSON}, &out)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	logger := slog.New(h).With(SessionKey, "s1")
+
+	ctx := WithTraceID(context.Background(), "t1")
+	ctx = WithAttrs(ctx, SessionKey, "s1", ToolKey, "get_current_time")
+	ctx = WithAttrs(ctx, ToolKey, "get_drink_recipe")
+	logger.InfoContext(ctx, "tool call")
+
+	if strings.Count(out.String(), `"session"`) != 1 {
+		t.Errorf("expected session logged once, got: %s", out.String())
+	}
+	var line map[string]any
+	if err := json.Unmarshal([]byte(out.String()), &line); err != nil {
+		t.Fatalf("invalid JSON line: %v", err)
+	}
+	if line[TraceIDKey] != "t1" || line[ToolKey] != "get_drink_recipe" || line[SessionKey] != "s1" {
+		t.Errorf("unexpected attributes: %v", line)
+	}
+	if TraceID(ctx) != "t1" || TraceID(context.Background()) != "" {
+		t.Errorf("unexpected trace IDs: %q", TraceID(ctx))
+	}
+}
+
+func TestHandler_Redaction(t *testing.T) {
+	SetSecrets("tok-123456")
+	defer SetSecrets()
+	AddRedactor(func(s string) string { return strings.ReplaceAll(s, "client-42", "client-*") })
+
+	var out strings.Builder
+	h, err := NewHandler(Config{Redact: []string{`(?i)room \d+`}}, &out)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	slog.New(h).Info("guest ala@example.com in Room 12",
+		"prompt", "call me at +48 601 202 303, I'm client-42, order 100 200 300",
+		"header", "Authorization: Bearer tok-123456",
+		"queries", []string{"sk-abcdefghijklmnopqrst"},
+	)
+
+	got := out.String()
+	for _, leaked := range []string{"ala@example.com", "Room 12", "601 202 303", "client-42", "tok-123456", "sk-abcdef"} {
+		if strings.Contains(got, leaked) {
+			t.Errorf("%q not redacted: %s", leaked, got)
+		}
+	}
+	if !strings.Contains(got, "client-*") || !strings.Contains(got, "order 100 200 300") || !strings.Contains(got, Redacted) {
+		t.Errorf("expected masked text, got: %s", got)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{Level: "verbose"}, `unknown log level "verbose"`},
+		{Config{Format: "xml"}, `unknown log format "xml"`},
+		{Config{Components: map[string]string{"aiclient": "loud"}}, "component aiclient"},
+		{Config{Redact: []string{"("}}, "invalid redact pattern"},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("expected error %q, got: %v", tt.want, err)
+		}
+	}
+	if err := (Config{Level: "DEBUG", Format: FormatJSON}).Validate(); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package applog
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces masked text.
+const Redacted = "[REDACTED]"
+
+// piiPatterns mask personal data and credentials in every log line:
+// e-mails, international phone numbers (+48 601 202 303), card numbers,
+// bearer tokens and API keys. Local phone numbers look like any digit groups,
+// e.g. quantities or IDs, mask them with a log.redact pattern.
+var piiPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+	regexp.MustCompile(`\+\d{1,3}[ -]?\d{2,4}(?:[ -]?\d{2,4}){2,3}\b`),
+	regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/=-]+`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{16,}`),
+}
+
+var (
+	redactMu  sync.RWMutex
+	secrets   []string
+	redactors []func(string) string
+)
+
+// SetSecrets replaces values masked wherever they appear, e.g. API tokens from config.
+func SetSecrets(values ...string) {
+	redactMu.Lock()
+	defer redactMu.Unlock()
+	secrets = secrets[:0]
+	for _, v := range values {
+		if v != "" {
+			secrets = append(secrets, v)
+		}
+	}
+}
+
+// AddRedactor adds a hook masking text of log lines, e.g. customer IDs.
+func AddRedactor(fn func(string) string) {
+	redactMu.Lock()
+	defer redactMu.Unlock()
+	redactors = append(redactors, fn)
+}
+
+type redactor struct {
+	patterns []*regexp.Regexp
+}
+
+func newRedactor(patterns []string) (*redactor, error) {
+	r := &redactor{patterns: append([]*regexp.Regexp{}, piiPatterns...)}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// redact masks secrets, PII and configured patterns in s.
+func (r *redactor) redact(s string) string {
+	redactMu.RLock()
+	defer redactMu.RUnlock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, Redacted)
+	}
+	for _, fn := range redactors {
+		s = fn(s)
+	}
+	return s
+}
+
+// replaceAttr masks text attributes and the message; errors and other
+// values are logged as their masked text.
+func (r *redactor) replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if a.Key == slog.LevelKey {
+			return a
+		}
+		return slog.String(a.Key, r.redact(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, r.redact(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, r.redact(v.String()))
+		case []string:
+			masked := make([]string, len(v))
+			for i, s := range v {
+				masked[i] = r.redact(s)
+			}
+			return slog.Any(a.Key, masked)
+		}
+	}
+	return a
+}
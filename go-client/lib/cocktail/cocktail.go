@@ -1,45 +1,84 @@
 package cocktail
 
 import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/gocarina/gocsv"
 	"github.com/weaviate/weaviate/entities/models"
 )
 
 var CocktailClassName = "Cocktail"
-var VectorizerName = "text2vec-transformers" // "none" - jeśli embedding dostarczamy sami
-
-var CocktailClass = models.Class{
-	Class:       CocktailClassName,
-	Description: "Alcoholic drink",
-	Vectorizer:  VectorizerName,
-	Properties: []*models.Property{
-		{
-			Name:     "name",
-			DataType: []string{"text"},
-		},
-		{
-			Name:     "ingredients",
-			DataType: []string{"text"},
-		},
-		{
-			Name:     "preparation",
-			DataType: []string{"text"},
-			ModuleConfig: map[string]interface{}{
-				VectorizerName: map[string]interface{}{
-					"skip": true, // exclude from embedding
-				},
+var VectorizerName = "text2vec-transformers"
+var VectorizerNone = "none" // embedding provided by the app, see EmbedFunc
+
+var CocktailClass = NewCocktailClass(VectorizerName)
+
+// EmbedFunc computes a vector for a text, used when the class has no vectorizer.
+type EmbedFunc func(ctx context.Context, text string) ([]float32, error)
+
+func NewCocktailClass(vectorizer string) models.Class {
+	class := models.Class{
+		Class:       CocktailClassName,
+		Description: "Alcoholic drink",
+		Vectorizer:  vectorizer,
+		Properties: []*models.Property{
+			{
+				Name:     "name",
+				DataType: []string{"text"},
+			},
+			{
+				Name:     "ingredients",
+				DataType: []string{"text"},
+			},
+			{
+				Name:     "preparation",
+				DataType: []string{"text"},
 			},
 		},
-	},
-	ModuleConfig: map[string]interface{}{
-		VectorizerName: map[string]interface{}{
-			"vectorizeClassName":    false,
-			"vectorizePropertyName": true,
-		},
-	},
+	}
+
+	if vectorizer != VectorizerNone {
+		class.Properties[2].ModuleConfig = map[string]interface{}{
+			vectorizer: map[string]interface{}{
+				"skip": true, // exclude from embedding
+			},
+		}
+		class.ModuleConfig = map[string]interface{}{
+			vectorizer: map[string]interface{}{
+				"vectorizeClassName":    false,
+				"vectorizePropertyName": true,
+			},
+		}
+	}
+
+	return class
+}
+
+// embeddingText is the text vectorized for a cocktail, it mirrors what
+// the Weaviate vectorizer sees: property names with values, no preparation.
+func embeddingText(c Cocktail) string {
+	return "name " + c.Name + " ingredients " + c.Ingredients
 }
 
 type Cocktail struct {
-	Name        string `csv:"Cocktail Name"`
-	Ingredients string `csv:"Ingredients"`
-	Preparation string `csv:"Preparation"`
+	Name        string `csv:"Cocktail Name" json:"name"`
+	Ingredients string `csv:"Ingredients" json:"ingredients"`
+	Preparation string `csv:"Preparation" json:"preparation"`
+}
+
+// LoadCSV reads cocktails from a CSV file with the Cocktail csv column names.
+func LoadCSV(path string) ([]Cocktail, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cocktails []Cocktail
+	if err := gocsv.UnmarshalFile(f, &cocktails); err != nil {
+		return nil, fmt.Errorf("CSV parsing error in %s: %w", path, err)
+	}
+	return cocktails, nil
 }
@@ -0,0 +1,390 @@
+package cocktail
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// BM25 parameters and hybrid alpha, Weaviate defaults
+	bm25K1             = 1.2
+	bm25B              = 0.75
+	defaultHybridAlpha = 0.75
+)
+
+type memoryItem struct {
+	cocktail Cocktail
+	vector   []float32
+}
+
+// memoryStore keeps cocktails in process memory. Vector search compares
+// vectors from embed by cosine distance, like a Weaviate collection with
+// vectorizer "none"; without embed only BM25 and name search work.
+type memoryStore struct {
+	embed EmbedFunc
+
+	mu    sync.RWMutex
+	items []memoryItem
+}
+
+func NewMemoryStore(embed EmbedFunc) *memoryStore {
+	return &memoryStore{embed: embed}
+}
+
+// InitClass does nothing, the store needs no schema.
+func (s *memoryStore) InitClass(ctx context.Context) error {
+	return nil
+}
+
+func (s *memoryStore) ClearClass(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = nil
+	return nil
+}
+
+func (s *memoryStore) Save(ctx context.Context, c Cocktail) error {
+	item := memoryItem{cocktail: c}
+	if s.embed != nil {
+		vector, err := s.embed(ctx, embeddingText(c))
+		if err != nil {
+			return fmt.Errorf("embedding of %s: %w", c.Name, err)
+		}
+		item.vector = vector
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = append(s.items, item)
+	return nil
+}
+
+func (s *memoryStore) GetListByNearText(ctx context.Context, text string, limit int) ([]Cocktail, error) {
+	results, err := s.Search(ctx, SearchQuery{
+		Text:     text,
+		Mode:     SearchNearText,
+		Limit:    limit,
+		Distance: 0.6,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cocktails := make([]Cocktail, 0, len(results))
+	for _, result := range results {
+		cocktails = append(cocktails, result.Cocktail)
+	}
+	return cocktails, nil
+}
+
+func (s *memoryStore) GetByCocktailName(ctx context.Context, name string) (Cocktail, error) {
+	results, err := s.Search(ctx, SearchQuery{Text: name, Mode: SearchName})
+	if err != nil {
+		return Cocktail{}, err
+	}
+
+	if len(results) > 1 {
+		return Cocktail{}, fmt.Errorf("More than one result found")
+	}
+
+	if len(results) == 0 {
+		return Cocktail{}, nil
+	}
+
+	return results[0].Cocktail, nil
+}
+
+func (s *memoryStore) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	s.mu.RLock()
+	items := make([]memoryItem, 0, len(s.items))
+	for _, item := range s.items {
+		if matchFilters(item.cocktail, q.Filters) {
+			items = append(items, item)
+		}
+	}
+	s.mu.RUnlock()
+
+	var results []SearchResult
+	switch q.Mode {
+	case SearchNearText, "":
+		distances, err := s.distances(ctx, q.Text, items)
+		if err != nil {
+			return nil, err
+		}
+		for i, item := range items {
+			if q.Distance > 0 && distances[i] > q.Distance {
+				continue
+			}
+			distance := distances[i]
+			certainty := 1 - distance/2
+			results = append(results, SearchResult{Cocktail: item.cocktail, Distance: &distance, Certainty: &certainty})
+		}
+		sort.SliceStable(results, func(i, j int) bool {
+			return *results[i].Distance < *results[j].Distance
+		})
+	case SearchBM25:
+		scores := bm25Scores(q.Text, items)
+		for i, item := range items {
+			if scores[i] > 0 {
+				score := float32(scores[i])
+				results = append(results, SearchResult{Cocktail: item.cocktail, Score: &score})
+			}
+		}
+		sortByScore(results)
+	case SearchHybrid:
+		distances, err := s.distances(ctx, q.Text, items)
+		if err != nil {
+			return nil, err
+		}
+		alpha := float32(defaultHybridAlpha)
+		if q.Alpha != nil {
+			alpha = *q.Alpha
+		}
+		scores := hybridScores(alpha, distances, bm25Scores(q.Text, items))
+		for i, item := range items {
+			score := scores[i]
+			results = append(results, SearchResult{Cocktail: item.cocktail, Score: &score})
+		}
+		sortByScore(results)
+	case SearchName:
+		for _, item := range items {
+			if strings.EqualFold(strings.TrimSpace(item.cocktail.Name), strings.TrimSpace(q.Text)) {
+				results = append(results, SearchResult{Cocktail: item.cocktail})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown search mode %q", q.Mode)
+	}
+
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, nil
+}
+
+// distances returns cosine distances between the text and the items.
+func (s *memoryStore) distances(ctx context.Context, text string, items []memoryItem) ([]float32, error) {
+	if s.embed == nil {
+		return nil, fmt.Errorf("vector search in memory store needs an embedding model")
+	}
+	vector, err := s.embed(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("query embedding: %w", err)
+	}
+
+	distances := make([]float32, len(items))
+	for i, item := range items {
+		distances[i] = 1 - cosineSimilarity(vector, item.vector)
+	}
+	return distances, nil
+}
+
+func cosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
+
+// bm25Scores scores items by BM25 over keywordProperties, each property
+// with its own statistics, weighted by its boost (name^2).
+func bm25Scores(text string, items []memoryItem) []float64 {
+	scores := make([]float64, len(items))
+	terms := tokenize(text)
+	if len(terms) == 0 || len(items) == 0 {
+		return scores
+	}
+
+	for _, property := range keywordProperties {
+		name, boost := propertyBoost(property)
+
+		docs := make([][]string, len(items))
+		totalLen := 0
+		docFreq := map[string]int{}
+		for i, item := range items {
+			docs[i] = tokenize(propertyValue(item.cocktail, name))
+			totalLen += len(docs[i])
+			seen := map[string]bool{}
+			for _, token := range docs[i] {
+				if !seen[token] {
+					seen[token] = true
+					docFreq[token]++
+				}
+			}
+		}
+		if totalLen == 0 {
+			continue
+		}
+		avgLen := float64(totalLen) / float64(len(items))
+
+		for i, doc := range docs {
+			for _, term := range terms {
+				tf := 0
+				for _, token := range doc {
+					if token == term {
+						tf++
+					}
+				}
+				if tf == 0 {
+					continue
+				}
+				n := float64(docFreq[term])
+				idf := math.Log(1 + (float64(len(items))-n+0.5)/(n+0.5))
+				norm := float64(tf) + bm25K1*(1-bm25B+bm25B*float64(len(doc))/avgLen)
+				scores[i] += boost * idf * float64(tf) * (bm25K1 + 1) / norm
+			}
+		}
+	}
+	return scores
+}
+
+// hybridScores fuses vector and BM25 results like Weaviate relative score
+// fusion: both are min-max normalised, then weighted by alpha.
+func hybridScores(alpha float32, distances []float32, bm25 []float64) []float32 {
+	similarity := make([]float64, len(distances))
+	for i, d := range distances {
+		similarity[i] = float64(1 - d)
+	}
+	vectorNorm := normalize(similarity)
+	keywordNorm := normalize(bm25)
+
+	scores := make([]float32, len(distances))
+	for i := range scores {
+		scores[i] = alpha*float32(vectorNorm[i]) + (1-alpha)*float32(keywordNorm[i])
+	}
+	return scores
+}
+
+func normalize(values []float64) []float64 {
+	result := make([]float64, len(values))
+	if len(values) == 0 {
+		return result
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo = min(lo, v)
+		hi = max(hi, v)
+	}
+	for i, v := range values {
+		if hi > lo {
+			result[i] = (v - lo) / (hi - lo)
+		} else if hi > 0 {
+			result[i] = 1
+		}
+	}
+	return result
+}
+
+func sortByScore(results []SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return *results[i].Score > *results[j].Score
+	})
+}
+
+// matchFilters mirrors Weaviate filters on text properties with word
+// tokenization: Equal matches when all words of the value are in the property.
+func matchFilters(c Cocktail, fs []Filter) bool {
+	for _, f := range fs {
+		words := tokenize(propertyValue(c, f.Property))
+		var matched bool
+		switch f.Operator {
+		case FilterLike:
+			matched = matchLike(f.Value, words)
+		case FilterNotEqual:
+			matched = !containsAll(words, tokenize(f.Value))
+		default:
+			matched = containsAll(words, tokenize(f.Value))
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAll(words []string, values []string) bool {
+	if len(values) == 0 {
+		return false
+	}
+	for _, v := range values {
+		found := false
+		for _, w := range words {
+			if w == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// matchLike matches every pattern word (with * and ? wildcards) against property words.
+func matchLike(pattern string, words []string) bool {
+	patterns := strings.Fields(strings.ToLower(pattern))
+	if len(patterns) == 0 {
+		return false
+	}
+	for _, p := range patterns {
+		found := false
+		for _, w := range words {
+			if ok, _ := path.Match(p, w); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// propertyBoost splits a property like name^2 into name and its weight.
+func propertyBoost(property string) (string, float64) {
+	name, boost, ok := strings.Cut(property, "^")
+	if !ok {
+		return name, 1
+	}
+	var weight float64
+	if _, err := fmt.Sscanf(boost, "%g", &weight); err != nil {
+		return name, 1
+	}
+	return name, weight
+}
+
+func propertyValue(c Cocktail, property string) string {
+	switch property {
+	case "name":
+		return c.Name
+	case "ingredients":
+		return c.Ingredients
+	case "preparation":
+		return c.Preparation
+	default:
+		return ""
+	}
+}
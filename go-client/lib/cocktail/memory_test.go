@@ -0,0 +1,119 @@
+package cocktail
+
+import (
+	"context"
+	"go-client/lib/fakellm"
+	"testing"
+)
+
+var testCocktails = []Cocktail{
+	{Name: "Negroni", Ingredients: "1 oz Gin, 1 oz Campari, 1 oz Sweet Vermouth", Preparation: "Stir"},
+	{Name: "Mezcal Negroni", Ingredients: "1 oz Mezcal, 1 oz Campari, 1 oz Sweet Vermouth", Preparation: "Stir"},
+	{Name: "Margarita", Ingredients: "2 oz Tequila, 1 oz Lime Juice, .75 oz Cointreau", Preparation: "Shake"},
+	{Name: "Daiquiri", Ingredients: "2 oz Rum, 1 oz Lime Juice, .75 oz Simple Syrup", Preparation: "Shake"},
+}
+
+func newTestMemoryStore(t *testing.T, embed EmbedFunc) *memoryStore {
+	t.Helper()
+	s := NewMemoryStore(embed)
+	for _, c := range testCocktails {
+		if err := s.Save(context.Background(), c); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	return s
+}
+
+func fakeEmbed(ctx context.Context, text string) ([]float32, error) {
+	return fakellm.Embed(text), nil
+}
+
+func names(results []SearchResult) []string {
+	n := make([]string, len(results))
+	for i, r := range results {
+		n[i] = r.Name
+	}
+	return n
+}
+
+func TestMemoryStore_Search(t *testing.T) {
+	s := newTestMemoryStore(t, fakeEmbed)
+	alpha := float32(0.5)
+
+	tests := []struct {
+		name     string
+		query    SearchQuery
+		expected []string
+	}{
+		{"bm25 name boost", SearchQuery{Text: "negroni", Mode: SearchBM25}, []string{"Negroni", "Mezcal Negroni"}},
+		{"bm25 ingredients", SearchQuery{Text: "lime rum", Mode: SearchBM25, Limit: 1}, []string{"Daiquiri"}},
+		{"name exact", SearchQuery{Text: "negroni", Mode: SearchName}, []string{"Negroni"}},
+		{"near-text", SearchQuery{Text: "mezcal campari", Mode: SearchNearText, Limit: 1}, []string{"Mezcal Negroni"}},
+		{"hybrid", SearchQuery{Text: "tequila lime", Mode: SearchHybrid, Alpha: &alpha, Limit: 1}, []string{"Margarita"}},
+		{
+			"filters",
+			SearchQuery{Text: "campari", Mode: SearchBM25, Filters: []Filter{{Property: "ingredients", Operator: FilterNotEqual, Value: "gin"}}},
+			[]string{"Mezcal Negroni"},
+		},
+		{
+			"like",
+			SearchQuery{Text: "lime", Mode: SearchBM25, Filters: []Filter{{Property: "name", Operator: FilterLike, Value: "Marg*"}}},
+			[]string{"Margarita"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := s.Search(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			got := names(results)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("expected %v, got %v", tt.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStore_NearTextDistance(t *testing.T) {
+	s := newTestMemoryStore(t, fakeEmbed)
+
+	results, err := s.Search(context.Background(), SearchQuery{Text: "mezcal", Mode: SearchNearText, Distance: 0.9})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for _, r := range results {
+		if *r.Distance > 0.9 {
+			t.Errorf("%s: distance %.2f over threshold", r.Name, *r.Distance)
+		}
+	}
+	if len(results) == 0 || results[0].Name != "Mezcal Negroni" {
+		t.Errorf("expected Mezcal Negroni first, got %v", names(results))
+	}
+}
+
+func TestMemoryStore_WithoutEmbedder(t *testing.T) {
+	s := newTestMemoryStore(t, nil)
+
+	if _, err := s.Search(context.Background(), SearchQuery{Text: "gin", Mode: SearchNearText}); err == nil {
+		t.Error("expected error for vector search without embedder, got nil")
+	}
+
+	c, err := s.GetByCocktailName(context.Background(), "Daiquiri")
+	if err != nil || c.Name != "Daiquiri" {
+		t.Errorf("expected Daiquiri, got %+v, %v", c, err)
+	}
+
+	if err := s.ClearClass(context.Background()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if c, _ := s.GetByCocktailName(context.Background(), "Daiquiri"); c.Name != "" {
+		t.Errorf("expected empty store after clear, got %+v", c)
+	}
+}
@@ -3,7 +3,7 @@ package cocktail
 import (
 	"context"
 	"fmt"
-	"log"
+	"go-client/lib/applog"
 
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
@@ -12,42 +12,60 @@ import (
 )
 
 type cocktailRepository struct {
-	client *weaviate.Client
-	ctx    *context.Context
+	client     *weaviate.Client
+	vectorizer string
+	embed      EmbedFunc
 }
 
-func NewRepository(client *weaviate.Client, ctx *context.Context) *cocktailRepository {
+// NewRepository creates a repository for Cocktail class vectorized by the
+// Weaviate module vectorizer (default VectorizerName). With VectorizerNone
+// vectors are computed by embed.
+func NewRepository(client *weaviate.Client, vectorizer string, embed EmbedFunc) *cocktailRepository {
+	if vectorizer == "" {
+		vectorizer = VectorizerName
+	}
 	return &cocktailRepository{
-		client: client,
-		ctx:    ctx,
+		client:     client,
+		vectorizer: vectorizer,
+		embed:      embed,
 	}
 }
 
-func (r *cocktailRepository) InitClass() error {
-	err := r.client.Schema().ClassCreator().WithClass(&CocktailClass).Do(*r.ctx)
+func (r *cocktailRepository) InitClass(ctx context.Context) error {
+	class := NewCocktailClass(r.vectorizer)
+	err := r.client.Schema().ClassCreator().WithClass(&class).Do(ctx)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *cocktailRepository) ClearClass() error {
-	err := r.client.Schema().ClassDeleter().WithClassName(CocktailClassName).Do(*r.ctx)
+func (r *cocktailRepository) ClearClass(ctx context.Context) error {
+	err := r.client.Schema().ClassDeleter().WithClassName(CocktailClassName).Do(ctx)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *cocktailRepository) Save(c Cocktail) error {
-	_, err := r.client.Data().Creator().
+func (r *cocktailRepository) Save(ctx context.Context, c Cocktail) error {
+	creator := r.client.Data().Creator().
 		WithClassName(CocktailClassName).
 		WithProperties(map[string]interface{}{
 			"name":        c.Name,
 			"ingredients": c.Ingredients,
 			"preparation": c.Preparation,
-		}).
-		Do(context.Background())
+		})
+
+	if r.embed != nil {
+		vector, err := r.embed(ctx, embeddingText(c))
+		if err != nil {
+			return fmt.Errorf("embedding of %s: %w", c.Name, err)
+		}
+		creator = creator.WithVector(vector)
+	}
+
+	_, err := creator.Do(ctx)
 
 	if err != nil {
 		return err
@@ -58,32 +76,25 @@ func (r *cocktailRepository) Save(c Cocktail) error {
 // Queries
 // https://docs.weaviate.io/weaviate/api/graphql/search-operators
 
-func (r *cocktailRepository) GetListByNearText(text string, limit int) ([]Cocktail, error) {
-	fields := []graphql.Field{
-		{Name: "name"},
-		{Name: "ingredients"},
-		{Name: "preparation"},
-	}
-
-	nearText := r.client.GraphQL().
-		NearTextArgBuilder().
-		WithConcepts([]string{text}).
-		WithDistance(0.6)
-
-	response, err := r.client.GraphQL().Get().
-		WithClassName(CocktailClassName).
-		WithFields(fields...).
-		WithNearText(nearText).
-		WithLimit(limit).
-		Do(*r.ctx)
+func (r *cocktailRepository) GetListByNearText(ctx context.Context, text string, limit int) ([]Cocktail, error) {
+	results, err := r.Search(ctx, SearchQuery{
+		Text:     text,
+		Mode:     SearchNearText,
+		Limit:    limit,
+		Distance: 0.6,
+	})
 	if err != nil {
-		log.Fatal("WV Fatal", err)
+		return nil, err
 	}
 
-	return r.buildResult(response)
+	cocktails := make([]Cocktail, 0, len(results))
+	for _, result := range results {
+		cocktails = append(cocktails, result.Cocktail)
+	}
+	return cocktails, nil
 }
 
-func (r *cocktailRepository) GetByCocktailName(name string) (Cocktail, error) {
+func (r *cocktailRepository) GetByCocktailName(ctx context.Context, name string) (Cocktail, error) {
 	fields := []graphql.Field{
 		{Name: "name"},
 		{Name: "ingredients"},
@@ -99,9 +110,9 @@ func (r *cocktailRepository) GetByCocktailName(name string) (Cocktail, error) {
 		WithClassName(CocktailClassName).
 		WithFields(fields...).
 		WithWhere(where).
-		Do(*r.ctx)
+		Do(ctx)
 	if err != nil {
-		log.Fatal("WV Fatal", err)
+		return Cocktail{}, err
 	}
 
 	result, err := r.buildResult(response)
@@ -123,34 +134,51 @@ func (r *cocktailRepository) GetByCocktailName(name string) (Cocktail, error) {
 func (r *cocktailRepository) buildResult(result *models.GraphQLResponse) ([]Cocktail, error) {
 	cocktails := []Cocktail{}
 
+	items, err := resultItems(result)
+	if err != nil {
+		return cocktails, err
+	}
+
+	for _, item := range items {
+		cocktails = append(cocktails, cocktailFromItem(item))
+	}
+	return cocktails, nil
+}
+
+func resultItems(result *models.GraphQLResponse) ([]map[string]interface{}, error) {
 	if len(result.Errors) > 0 {
 		for _, gqlErr := range result.Errors {
-			log.Printf("Błąd GraphQL: %s", gqlErr.Message)
+			applog.Component("cocktail").Error("GraphQL error", "message", gqlErr.Message)
 		}
-		log.Fatal("END")
+		return nil, fmt.Errorf("GQL error: %s", result.Errors[0].Message)
 	}
 
 	// build response
 	getData, ok := result.Data["Get"].(map[string]interface{})
 	if !ok {
-		return cocktails, fmt.Errorf("no Get field in GQL response")
+		return nil, fmt.Errorf("no Get field in GQL response")
 	}
 
-	getCocktails, ok := getData["Cocktail"].([]interface{})
+	getCocktails, ok := getData[CocktailClassName].([]interface{})
 	if !ok {
-		return cocktails, fmt.Errorf("no Cocktail field in GQL response")
+		return nil, fmt.Errorf("no Cocktail field in GQL response")
 	}
 
+	items := make([]map[string]interface{}, 0, len(getCocktails))
 	for _, c := range getCocktails {
-		cocktail := c.(map[string]interface{})
-		cocktails = append(
-			cocktails,
-			Cocktail{
-				Name:        cocktail["name"].(string),
-				Ingredients: cocktail["ingredients"].(string),
-				Preparation: cocktail["preparation"].(string),
-			},
-		)
+		item, ok := c.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected Cocktail item in GQL response")
+		}
+		items = append(items, item)
 	}
-	return cocktails, nil
+	return items, nil
+}
+
+func cocktailFromItem(item map[string]interface{}) Cocktail {
+	c := Cocktail{}
+	c.Name, _ = item["name"].(string)
+	c.Ingredients, _ = item["ingredients"].(string)
+	c.Preparation, _ = item["preparation"].(string)
+	return c
 }
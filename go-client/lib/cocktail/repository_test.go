@@ -0,0 +1,49 @@
+package cocktail
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+func TestRepository_InitClassVectorizer(t *testing.T) {
+	tests := []struct {
+		vectorizer string
+		want       string
+	}{
+		{vectorizer: "", want: VectorizerName},
+		{vectorizer: "text2vec-ollama", want: "text2vec-ollama"},
+		{vectorizer: VectorizerNone, want: VectorizerNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			var created models.Class
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost && r.URL.Path == "/v1/schema" {
+					json.NewDecoder(r.Body).Decode(&created)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte("{}"))
+			}))
+			defer srv.Close()
+
+			client, err := weaviate.NewClient(weaviate.Config{Scheme: "http", Host: strings.TrimPrefix(srv.URL, "http://")})
+			if err != nil {
+				t.Fatalf("client: %v", err)
+			}
+			if err := NewRepository(client, tt.vectorizer, nil).InitClass(context.Background()); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if created.Class != CocktailClassName || created.Vectorizer != tt.want {
+				t.Errorf("expected class vectorized by %s, got %q by %q", tt.want, created.Class, created.Vectorizer)
+			}
+		})
+	}
+}
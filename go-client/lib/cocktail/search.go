@@ -0,0 +1,248 @@
+package cocktail
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+type SearchMode string
+
+const (
+	SearchNearText SearchMode = "near-text"
+	SearchBM25     SearchMode = "bm25"
+	SearchHybrid   SearchMode = "hybrid"
+	SearchName     SearchMode = "name"
+)
+
+var SearchModes = []SearchMode{SearchNearText, SearchBM25, SearchHybrid, SearchName}
+
+// keywordProperties are searched by BM25 (also in hybrid mode), name matches weigh more
+var keywordProperties = []string{"name^2", "ingredients"}
+
+func ParseSearchMode(s string) (SearchMode, error) {
+	for _, m := range SearchModes {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown search mode %q", s)
+}
+
+type FilterOperator string
+
+const (
+	FilterEqual    FilterOperator = "="
+	FilterNotEqual FilterOperator = "!="
+	FilterLike     FilterOperator = "~"
+)
+
+// Filter narrows search results by a cocktail property, e.g. ingredients!=gin
+type Filter struct {
+	Property string         `json:"property"`
+	Operator FilterOperator `json:"operator"`
+	Value    string         `json:"value"`
+}
+
+// ParseFilter reads a filter in the form property=value, property!=value
+// or property~value (Like, with * and ? wildcards).
+func ParseFilter(s string) (Filter, error) {
+	for _, op := range []FilterOperator{FilterNotEqual, FilterLike, FilterEqual} {
+		property, value, ok := strings.Cut(s, string(op))
+		if !ok {
+			continue
+		}
+		property = strings.TrimSpace(property)
+		if !isCocktailProperty(property) {
+			return Filter{}, fmt.Errorf("unknown property %q in filter %q", property, s)
+		}
+		return Filter{Property: property, Operator: op, Value: strings.TrimSpace(value)}, nil
+	}
+	return Filter{}, fmt.Errorf("invalid filter %q, expected property=value, property!=value or property~value", s)
+}
+
+func isCocktailProperty(name string) bool {
+	for _, p := range CocktailClass.Properties {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+type SearchQuery struct {
+	Text     string
+	Mode     SearchMode
+	Limit    int
+	Distance float32  // max vector distance, 0 means no threshold (near-text only)
+	Alpha    *float32 // hybrid weighting, 0 - pure BM25, 1 - pure vector, nil - Weaviate default
+	Filters  []Filter
+}
+
+// SearchResult is a cocktail with the scores Weaviate reported for it.
+// Distance and certainty come from vector search, score from BM25 and hybrid search.
+type SearchResult struct {
+	Cocktail
+	Distance  *float32 `json:"distance,omitempty"`
+	Certainty *float32 `json:"certainty,omitempty"`
+	Score     *float32 `json:"score,omitempty"`
+}
+
+func (r *cocktailRepository) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	fields := []graphql.Field{
+		{Name: "name"},
+		{Name: "ingredients"},
+		{Name: "preparation"},
+	}
+
+	get := r.client.GraphQL().Get().
+		WithClassName(CocktailClassName)
+
+	switch q.Mode {
+	case SearchNearText, "":
+		if r.embed != nil {
+			vector, err := r.embed(ctx, q.Text)
+			if err != nil {
+				return nil, fmt.Errorf("query embedding: %w", err)
+			}
+			nearVector := r.client.GraphQL().
+				NearVectorArgBuilder().
+				WithVector(vector)
+			if q.Distance > 0 {
+				nearVector = nearVector.WithDistance(q.Distance)
+			}
+			get = get.WithNearVector(nearVector)
+		} else {
+			nearText := r.client.GraphQL().
+				NearTextArgBuilder().
+				WithConcepts([]string{q.Text})
+			if q.Distance > 0 {
+				nearText = nearText.WithDistance(q.Distance)
+			}
+			get = get.WithNearText(nearText)
+		}
+		fields = append(fields, additionalFields("distance", "certainty"))
+	case SearchBM25:
+		bm25 := r.client.GraphQL().
+			Bm25ArgBuilder().
+			WithQuery(q.Text).
+			WithProperties(keywordProperties...)
+		get = get.WithBM25(bm25)
+		fields = append(fields, additionalFields("score"))
+	case SearchHybrid:
+		hybrid := r.client.GraphQL().
+			HybridArgumentBuilder().
+			WithQuery(q.Text).
+			WithProperties(keywordProperties)
+		if q.Alpha != nil {
+			hybrid = hybrid.WithAlpha(*q.Alpha)
+		}
+		if r.embed != nil {
+			vector, err := r.embed(ctx, q.Text)
+			if err != nil {
+				return nil, fmt.Errorf("query embedding: %w", err)
+			}
+			hybrid = hybrid.WithVector(vector)
+		}
+		get = get.WithHybrid(hybrid)
+		fields = append(fields, additionalFields("score"))
+	case SearchName:
+		q.Filters = append(q.Filters, Filter{Property: "name", Operator: FilterEqual, Value: q.Text})
+	default:
+		return nil, fmt.Errorf("unknown search mode %q", q.Mode)
+	}
+
+	if where := buildWhere(q.Filters); where != nil {
+		get = get.WithWhere(where)
+	}
+	if q.Limit > 0 {
+		get = get.WithLimit(q.Limit)
+	}
+
+	response, err := get.WithFields(fields...).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.buildSearchResult(response)
+}
+
+func additionalFields(names ...string) graphql.Field {
+	field := graphql.Field{Name: "_additional"}
+	for _, name := range names {
+		field.Fields = append(field.Fields, graphql.Field{Name: name})
+	}
+	return field
+}
+
+func buildWhere(fs []Filter) *filters.WhereBuilder {
+	operands := []*filters.WhereBuilder{}
+	for _, f := range fs {
+		where := filters.Where().
+			WithPath([]string{f.Property}).
+			WithValueText(f.Value)
+		switch f.Operator {
+		case FilterNotEqual:
+			where = where.WithOperator(filters.NotEqual)
+		case FilterLike:
+			where = where.WithOperator(filters.Like)
+		default:
+			where = where.WithOperator(filters.Equal)
+		}
+		operands = append(operands, where)
+	}
+
+	switch len(operands) {
+	case 0:
+		return nil
+	case 1:
+		return operands[0]
+	default:
+		return filters.Where().
+			WithOperator(filters.And).
+			WithOperands(operands)
+	}
+}
+
+func (r *cocktailRepository) buildSearchResult(response *models.GraphQLResponse) ([]SearchResult, error) {
+	items, err := resultItems(response)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(items))
+	for _, item := range items {
+		result := SearchResult{Cocktail: cocktailFromItem(item)}
+		if additional, ok := item["_additional"].(map[string]interface{}); ok {
+			result.Distance = additionalNumber(additional["distance"])
+			result.Certainty = additionalNumber(additional["certainty"])
+			result.Score = additionalNumber(additional["score"])
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// additionalNumber reads a number from _additional, Weaviate returns
+// some of them (e.g. hybrid score) as strings.
+func additionalNumber(v interface{}) *float32 {
+	switch n := v.(type) {
+	case float64:
+		f := float32(n)
+		return &f
+	case string:
+		parsed, err := strconv.ParseFloat(n, 32)
+		if err != nil {
+			return nil
+		}
+		f := float32(parsed)
+		return &f
+	default:
+		return nil
+	}
+}
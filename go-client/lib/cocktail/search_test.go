@@ -0,0 +1,30 @@
+package cocktail
+
+import "testing"
+
+func TestParseFilter(t *testing.T) {
+	tests := map[string]Filter{
+		"ingredients=gin":  {Property: "ingredients", Operator: FilterEqual, Value: "gin"},
+		"ingredients!=gin": {Property: "ingredients", Operator: FilterNotEqual, Value: "gin"},
+		"name~Neg*":        {Property: "name", Operator: FilterLike, Value: "Neg*"},
+		" name = Cove ":    {Property: "name", Operator: FilterEqual, Value: "Cove"},
+	}
+
+	for input, expected := range tests {
+		f, err := ParseFilter(input)
+		if err != nil {
+			t.Fatalf("%q: expected no error, got: %v", input, err)
+		}
+		if f != expected {
+			t.Errorf("%q: expected %+v, got %+v", input, expected, f)
+		}
+	}
+}
+
+func TestParseFilter_Invalid(t *testing.T) {
+	for _, input := range []string{"ingredients", "color=red", ""} {
+		if _, err := ParseFilter(input); err == nil {
+			t.Errorf("%q: expected error, got nil", input)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package cocktail
+
+import "context"
+
+// CocktailStore keeps cocktails and searches them. It is implemented by the
+// Weaviate repository and by an in-memory store for tests and lightweight mode.
+type CocktailStore interface {
+	InitClass(ctx context.Context) error
+	ClearClass(ctx context.Context) error
+	Save(ctx context.Context, c Cocktail) error
+	Search(ctx context.Context, q SearchQuery) ([]SearchResult, error)
+	GetListByNearText(ctx context.Context, text string, limit int) ([]Cocktail, error)
+	GetByCocktailName(ctx context.Context, name string) (Cocktail, error)
+}
+
+var (
+	_ CocktailStore = (*cocktailRepository)(nil)
+	_ CocktailStore = (*memoryStore)(nil)
+)
@@ -0,0 +1,321 @@
+package evaluation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-client/lib/aiclient"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ToolExpectation asserts that a tool was called, Arguments maps
+// argument names to regular expressions their values must match.
+type ToolExpectation struct {
+	Name      string            `yaml:"name" json:"name"`
+	Arguments map[string]string `yaml:"arguments" json:"arguments,omitempty"`
+}
+
+// AnswerExpectation asserts on the final answer (without <think> content).
+// Schema is a JSON Schema the answer must be valid against.
+type AnswerExpectation struct {
+	Regex    string                 `yaml:"regex" json:"regex,omitempty"`
+	NotRegex string                 `yaml:"notRegex" json:"notRegex,omitempty"`
+	Schema   map[string]interface{} `yaml:"schema" json:"schema,omitempty"`
+}
+
+type TurnExpectation struct {
+	Tools         []ToolExpectation `yaml:"tools" json:"tools,omitempty"`
+	NotTools      []string          `yaml:"notTools" json:"notTools,omitempty"`
+	Answer        AnswerExpectation `yaml:"answer" json:"answer"`
+	MaxToolRounds *int              `yaml:"maxToolRounds" json:"maxToolRounds,omitempty"`
+	MaxLatency    time.Duration     `yaml:"maxLatency" json:"maxLatency,omitempty"`
+}
+
+type ScriptTurn struct {
+	User   string          `yaml:"user" json:"user"`
+	Expect TurnExpectation `yaml:"expect" json:"expect"`
+}
+
+// Script is a scripted conversation with a chat, run in its own session.
+type Script struct {
+	Name  string       `yaml:"name" json:"name"`
+	Chat  string       `yaml:"chat" json:"chat"`
+	Turns []ScriptTurn `yaml:"turns" json:"turns"`
+
+	File string `yaml:"-" json:"file"`
+}
+
+// LoadScripts reads scripts from a YAML file or all *.yaml files in a directory.
+func LoadScripts(path string) ([]*Script, error) {
+	files := []string{path}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.yaml"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	}
+
+	scripts := []*Script{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read file %s: %w", file, err)
+		}
+		var script Script
+		if err := yaml.Unmarshal(data, &script); err != nil {
+			return nil, fmt.Errorf("YAML parsing error in %s: %w", file, err)
+		}
+		if len(script.Turns) == 0 {
+			return nil, fmt.Errorf("no turns in script %s", file)
+		}
+		if script.Name == "" {
+			script.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+		script.File = file
+		scripts = append(scripts, &script)
+	}
+	if len(scripts) == 0 {
+		return nil, fmt.Errorf("no scripts found in %s", path)
+	}
+	return scripts, nil
+}
+
+// Agent is a chat session under test, closed when its script finishes.
+type Agent interface {
+	Ask(ctx context.Context, inputMsg string) (string, error)
+	LastTurn() aiclient.TurnTrace
+	Close()
+}
+
+// AgentFactory starts a new session with a chat.
+type AgentFactory func(chat string) (Agent, error)
+
+type TurnResult struct {
+	User     string             `json:"user"`
+	Answer   string             `json:"answer"`
+	Trace    aiclient.TurnTrace `json:"trace"`
+	Latency  time.Duration      `json:"latency"`
+	Failures []string           `json:"failures,omitempty"`
+}
+
+type ScriptResult struct {
+	Name     string        `json:"name"`
+	File     string        `json:"file"`
+	Chat     string        `json:"chat"`
+	Passed   bool          `json:"passed"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Turns    []TurnResult  `json:"turns"`
+}
+
+type AgentsReport struct {
+	Passed   int            `json:"passed"`
+	Failed   int            `json:"failed"`
+	Duration time.Duration  `json:"duration"`
+	Scripts  []ScriptResult `json:"scripts"`
+}
+
+// RunScripts runs scripts at most parallel at a time, each with a new agent session.
+// Script results keep the order of scripts.
+func RunScripts(ctx context.Context, newAgent AgentFactory, scripts []*Script, parallel int) *AgentsReport {
+	if parallel <= 0 {
+		parallel = 1
+	}
+	start := time.Now()
+
+	results := make([]ScriptResult, len(scripts))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, script := range scripts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = runScript(ctx, newAgent, script)
+		}()
+	}
+	wg.Wait()
+
+	report := &AgentsReport{Scripts: results, Duration: time.Since(start)}
+	for _, r := range results {
+		if r.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+	}
+	return report
+}
+
+func runScript(ctx context.Context, newAgent AgentFactory, script *Script) ScriptResult {
+	result := ScriptResult{Name: script.Name, File: script.File, Chat: script.Chat, Passed: true}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	agent, err := newAgent(script.Chat)
+	if err != nil {
+		result.Passed = false
+		result.Error = err.Error()
+		return result
+	}
+	defer agent.Close()
+
+	for _, turn := range script.Turns {
+		turnStart := time.Now()
+		answer, err := agent.Ask(ctx, turn.User)
+		turnResult := TurnResult{
+			User:    turn.User,
+			Answer:  answer,
+			Trace:   agent.LastTurn(),
+			Latency: time.Since(turnStart),
+		}
+		if err != nil {
+			turnResult.Failures = []string{fmt.Sprintf("ask error: %v", err)}
+		} else {
+			turnResult.Failures = checkTurn(turn.Expect, turnResult)
+		}
+		result.Turns = append(result.Turns, turnResult)
+
+		if len(turnResult.Failures) > 0 {
+			result.Passed = false
+		}
+		if err != nil {
+			// later turns depend on this answer
+			break
+		}
+	}
+	return result
+}
+
+func checkTurn(expect TurnExpectation, result TurnResult) []string {
+	failures := []string{}
+	answer := aiclient.StripThink(result.Answer)
+
+	for _, tool := range expect.Tools {
+		if err := findToolCall(tool, result.Trace.ToolCalls); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	for _, name := range expect.NotTools {
+		for _, call := range result.Trace.ToolCalls {
+			if call.Name == name {
+				failures = append(failures, fmt.Sprintf("tool %s was called, but should not be", name))
+				break
+			}
+		}
+	}
+
+	if expect.Answer.Regex != "" {
+		if err := matchRegex(expect.Answer.Regex, answer, true); err != nil {
+			failures = append(failures, fmt.Sprintf("answer: %v", err))
+		}
+	}
+	if expect.Answer.NotRegex != "" {
+		if err := matchRegex(expect.Answer.NotRegex, answer, false); err != nil {
+			failures = append(failures, fmt.Sprintf("answer: %v", err))
+		}
+	}
+	if expect.Answer.Schema != nil {
+		if err := matchSchema(expect.Answer.Schema, answer); err != nil {
+			failures = append(failures, fmt.Sprintf("answer doesn't match schema: %v", err))
+		}
+	}
+
+	if expect.MaxToolRounds != nil && result.Trace.ToolRounds > *expect.MaxToolRounds {
+		failures = append(failures, fmt.Sprintf("%d tool rounds, max %d", result.Trace.ToolRounds, *expect.MaxToolRounds))
+	}
+	if expect.MaxLatency > 0 && result.Latency > expect.MaxLatency {
+		failures = append(failures, fmt.Sprintf("latency %s over budget %s", result.Latency.Round(time.Millisecond), expect.MaxLatency))
+	}
+
+	return failures
+}
+
+func findToolCall(expect ToolExpectation, calls []aiclient.ToolTrace) error {
+	called := false
+	var lastErr error
+	for _, call := range calls {
+		if call.Name != expect.Name {
+			continue
+		}
+		called = true
+		if lastErr = matchArguments(expect.Arguments, call.Arguments); lastErr == nil {
+			return nil
+		}
+	}
+	if !called {
+		return fmt.Errorf("tool %s was not called", expect.Name)
+	}
+	return fmt.Errorf("tool %s called with unexpected arguments: %v", expect.Name, lastErr)
+}
+
+func matchArguments(expected map[string]string, arguments string) error {
+	if len(expected) == 0 {
+		return nil
+	}
+
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return fmt.Errorf("arguments are not valid JSON: %v", err)
+	}
+
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value, ok := args[name]
+		if !ok {
+			return fmt.Errorf("argument %s is missing", name)
+		}
+		text, ok := value.(string)
+		if !ok {
+			b, _ := json.Marshal(value)
+			text = string(b)
+		}
+		if err := matchRegex(expected[name], text, true); err != nil {
+			return fmt.Errorf("argument %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func matchRegex(pattern string, text string, shouldMatch bool) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid regex %q: %v", pattern, err)
+	}
+	if re.MatchString(text) != shouldMatch {
+		if shouldMatch {
+			return fmt.Errorf("%q doesn't match /%s/", text, pattern)
+		}
+		return fmt.Errorf("%q matches /%s/", text, pattern)
+	}
+	return nil
+}
+
+func matchSchema(schema map[string]interface{}, answer string) error {
+	// YAML schema is converted through JSON into jsonschema.Definition
+	definition, err := aiclient.SchemaDefinition(schema)
+	if err != nil {
+		return err
+	}
+	// models like to wrap JSON in a markdown code block
+	return aiclient.ValidateJSON(definition, aiclient.CleanJSON(answer))
+}
@@ -0,0 +1,127 @@
+package evaluation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go-client/lib/aiclient"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeAgent struct {
+	answers []string
+	traces  []aiclient.TurnTrace
+	turn    int
+	closed  bool
+}
+
+func (f *fakeAgent) Ask(ctx context.Context, inputMsg string) (string, error) {
+	if f.turn >= len(f.answers) {
+		return "", fmt.Errorf("no more answers")
+	}
+	f.turn++
+	return f.answers[f.turn-1], nil
+}
+
+func (f *fakeAgent) LastTurn() aiclient.TurnTrace {
+	return f.traces[f.turn-1]
+}
+
+func (f *fakeAgent) Close() {
+	f.closed = true
+}
+
+func TestLoadScripts(t *testing.T) {
+	dir := t.TempDir()
+	script := `
+chat: waiter
+turns:
+  - user: "hi"
+    expect:
+      maxLatency: 30s
+      maxToolRounds: 0
+`
+	if err := os.WriteFile(filepath.Join(dir, "hello.yaml"), []byte(script), 0644); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+
+	scripts, err := LoadScripts(dir)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(scripts) != 1 || scripts[0].Name != "hello" {
+		t.Fatalf("unexpected scripts: %+v", scripts)
+	}
+	expect := scripts[0].Turns[0].Expect
+	if expect.MaxLatency != 30*time.Second || expect.MaxToolRounds == nil || *expect.MaxToolRounds != 0 {
+		t.Errorf("unexpected expectation: %+v", expect)
+	}
+}
+
+func TestRunScripts(t *testing.T) {
+	rounds := 0
+	scripts := []*Script{
+		{
+			Name: "passing",
+			Turns: []ScriptTurn{{
+				User: "mezcal please",
+				Expect: TurnExpectation{
+					Tools:         []ToolExpectation{{Name: "cocktail_list", Arguments: map[string]string{"user_description": "(?i)mezcal"}}},
+					NotTools:      []string{"cocktail_recipe"},
+					Answer:        AnswerExpectation{Regex: "Flor", Schema: map[string]interface{}{"type": "object", "required": []interface{}{"name"}}},
+					MaxToolRounds: &rounds,
+				},
+			}},
+		},
+		{
+			Name: "failing",
+			Turns: []ScriptTurn{{
+				User:   "recipe",
+				Expect: TurnExpectation{Tools: []ToolExpectation{{Name: "cocktail_recipe"}}, Answer: AnswerExpectation{NotRegex: "sorry"}},
+			}},
+		},
+	}
+
+	agents := map[string]*fakeAgent{
+		"passing": {
+			answers: []string{"<think>...</think>```json\n{\"name\": \"Flor de Amaras\"}\n```"},
+			traces:  []aiclient.TurnTrace{{ToolCalls: []aiclient.ToolTrace{{Name: "cocktail_list", Arguments: `{"user_description": "Mezcal"}`}}}},
+		},
+		"failing": {
+			answers: []string{"sorry"},
+			traces:  []aiclient.TurnTrace{{}},
+		},
+	}
+	newAgent := func(chat string) (Agent, error) {
+		return agents[chat], nil
+	}
+	for _, s := range scripts {
+		s.Chat = s.Name
+	}
+
+	report := RunScripts(context.Background(), newAgent, scripts, 2)
+	if report.Passed != 1 || report.Failed != 1 {
+		t.Fatalf("expected 1 passed and 1 failed, got: %+v", report)
+	}
+	if !report.Scripts[0].Passed {
+		t.Errorf("expected first script to pass, failures: %v", report.Scripts[0].Turns[0].Failures)
+	}
+	if failures := report.Scripts[1].Turns[0].Failures; len(failures) != 2 {
+		t.Errorf("expected 2 failures, got: %v", failures)
+	}
+	if !agents["passing"].closed || !agents["failing"].closed {
+		t.Error("expected agents closed after their scripts")
+	}
+
+	var junit bytes.Buffer
+	if err := report.WriteJUnit(&junit); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !strings.Contains(junit.String(), `<testsuites tests="2" failures="1"`) || !strings.Contains(junit.String(), "tool cocktail_recipe was not called") {
+		t.Errorf("unexpected JUnit report:\n%s", junit.String())
+	}
+}
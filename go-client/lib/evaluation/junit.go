@@ -0,0 +1,93 @@
+package evaluation
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report in JUnit XML format, one test case per script.
+func (r *AgentsReport) WriteJUnit(w io.Writer) error {
+	suite := junitTestSuite{
+		Name:     "agents",
+		Tests:    len(r.Scripts),
+		Failures: r.Failed,
+		Time:     seconds(r.Duration.Seconds()),
+	}
+
+	for _, s := range r.Scripts {
+		tc := junitTestCase{
+			Name:      s.Name,
+			ClassName: "agents." + s.Chat,
+			Time:      seconds(s.Duration.Seconds()),
+		}
+		if !s.Passed {
+			tc.Failure = &junitFailure{Message: "script failed", Text: s.failureText()}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	suites := junitTestSuites{
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func (s ScriptResult) failureText() string {
+	var builder strings.Builder
+	if s.Error != "" {
+		builder.WriteString(fmt.Sprintf("error: %s\n", s.Error))
+	}
+	for i, t := range s.Turns {
+		for _, f := range t.Failures {
+			builder.WriteString(fmt.Sprintf("turn %d (%q): %s\n", i+1, t.User, f))
+		}
+	}
+	return builder.String()
+}
+
+func seconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
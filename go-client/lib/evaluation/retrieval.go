@@ -0,0 +1,170 @@
+package evaluation
+
+import (
+	"context"
+	"fmt"
+	"go-client/lib/cocktail"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type RetrievalCase struct {
+	Query    string   `yaml:"query" json:"query"`
+	Expected []string `yaml:"expected" json:"expected"`
+}
+
+// RetrievalDataset is a list of queries with names of cocktails expected in top K results.
+type RetrievalDataset struct {
+	K     int             `yaml:"k"`
+	Cases []RetrievalCase `yaml:"cases"`
+}
+
+func LoadRetrievalDataset(path string) (*RetrievalDataset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read file %s: %w", path, err)
+	}
+
+	var ds RetrievalDataset
+	if err := yaml.Unmarshal(data, &ds); err != nil {
+		return nil, fmt.Errorf("YAML parsing error: %w", err)
+	}
+	if len(ds.Cases) == 0 {
+		return nil, fmt.Errorf("no cases in dataset %s", path)
+	}
+	if ds.K <= 0 {
+		ds.K = 5
+	}
+	return &ds, nil
+}
+
+type Metrics struct {
+	Recall    float64 `json:"recall"`
+	Precision float64 `json:"precision"`
+	MRR       float64 `json:"mrr"`
+}
+
+type CaseResult struct {
+	RetrievalCase
+	Retrieved []string `json:"retrieved"`
+	Metrics
+}
+
+type ModeReport struct {
+	Mode  cocktail.SearchMode `json:"mode"`
+	Cases []CaseResult        `json:"cases"`
+	Metrics
+}
+
+// RetrievalReport holds mean recall@k, precision@k and MRR per search mode
+// and over all modes. Its JSON form is stable, so reports can be diffed.
+type RetrievalReport struct {
+	K     int          `json:"k"`
+	Modes []ModeReport `json:"modes"`
+	Total Metrics      `json:"total"`
+}
+
+type SearchFunc func(ctx context.Context, q cocktail.SearchQuery) ([]cocktail.SearchResult, error)
+
+// RunRetrieval runs every dataset query in every mode, base provides
+// the remaining search settings (distance, alpha, filters).
+func RunRetrieval(ctx context.Context, search SearchFunc, ds *RetrievalDataset, modes []cocktail.SearchMode, base cocktail.SearchQuery) (*RetrievalReport, error) {
+	report := &RetrievalReport{K: ds.K}
+
+	for _, mode := range modes {
+		modeReport := ModeReport{Mode: mode}
+		for _, c := range ds.Cases {
+			q := base
+			q.Text = c.Query
+			q.Mode = mode
+			q.Limit = ds.K
+
+			results, err := search(ctx, q)
+			if err != nil {
+				return nil, fmt.Errorf("mode %s, query %q: %w", mode, c.Query, err)
+			}
+
+			retrieved := make([]string, len(results))
+			for i, r := range results {
+				retrieved[i] = r.Name
+			}
+			modeReport.Cases = append(modeReport.Cases, CaseResult{
+				RetrievalCase: c,
+				Retrieved:     retrieved,
+				Metrics:       RankingMetrics(retrieved, c.Expected, ds.K),
+			})
+		}
+
+		metrics := make([]Metrics, len(modeReport.Cases))
+		for i, c := range modeReport.Cases {
+			metrics[i] = c.Metrics
+		}
+		modeReport.Metrics = meanMetrics(metrics)
+		report.Modes = append(report.Modes, modeReport)
+	}
+
+	metrics := make([]Metrics, len(report.Modes))
+	for i, m := range report.Modes {
+		metrics[i] = m.Metrics
+	}
+	report.Total = meanMetrics(metrics)
+
+	return report, nil
+}
+
+// RankingMetrics computes recall@k, precision@k and reciprocal rank of the
+// first relevant item. Names are compared case-insensitively.
+func RankingMetrics(retrieved []string, expected []string, k int) Metrics {
+	relevant := map[string]bool{}
+	for _, e := range expected {
+		relevant[normalizeName(e)] = true
+	}
+	if len(relevant) == 0 || k <= 0 {
+		return Metrics{}
+	}
+
+	if len(retrieved) > k {
+		retrieved = retrieved[:k]
+	}
+
+	m := Metrics{}
+	hits := 0
+	found := map[string]bool{}
+	for i, r := range retrieved {
+		name := normalizeName(r)
+		if !relevant[name] || found[name] {
+			continue
+		}
+		found[name] = true
+		hits++
+		if m.MRR == 0 {
+			m.MRR = 1 / float64(i+1)
+		}
+	}
+	m.Recall = float64(hits) / float64(len(relevant))
+	m.Precision = float64(hits) / float64(k)
+	return m
+}
+
+func meanMetrics(metrics []Metrics) Metrics {
+	mean := Metrics{}
+	if len(metrics) == 0 {
+		return mean
+	}
+	for _, m := range metrics {
+		mean.Recall += m.Recall
+		mean.Precision += m.Precision
+		mean.MRR += m.MRR
+	}
+	n := float64(len(metrics))
+	mean.Recall /= n
+	mean.Precision /= n
+	mean.MRR /= n
+	return mean
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
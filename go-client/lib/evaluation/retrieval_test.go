@@ -0,0 +1,50 @@
+package evaluation
+
+import (
+	"context"
+	"go-client/lib/cocktail"
+	"math"
+	"testing"
+)
+
+func TestRankingMetrics(t *testing.T) {
+	m := RankingMetrics([]string{"A", "b ", "C", "D"}, []string{"B", "D", "E", "F"}, 4)
+
+	if m.Recall != 0.5 {
+		t.Errorf("expected recall 0.5, got %v", m.Recall)
+	}
+	if m.Precision != 0.5 {
+		t.Errorf("expected precision 0.5, got %v", m.Precision)
+	}
+	if m.MRR != 0.5 {
+		t.Errorf("expected MRR 0.5, got %v", m.MRR)
+	}
+
+	if m := RankingMetrics([]string{"A"}, []string{"B"}, 3); m != (Metrics{}) {
+		t.Errorf("expected zero metrics, got %+v", m)
+	}
+}
+
+func TestRunRetrieval(t *testing.T) {
+	search := func(ctx context.Context, q cocktail.SearchQuery) ([]cocktail.SearchResult, error) {
+		if q.Mode == cocktail.SearchBM25 {
+			return []cocktail.SearchResult{{Cocktail: cocktail.Cocktail{Name: "Negroni"}}}, nil
+		}
+		return []cocktail.SearchResult{}, nil
+	}
+	ds := &RetrievalDataset{K: 2, Cases: []RetrievalCase{{Query: "bitter", Expected: []string{"Negroni"}}}}
+
+	report, err := RunRetrieval(context.Background(), search, ds, []cocktail.SearchMode{cocktail.SearchBM25, cocktail.SearchNearText}, cocktail.SearchQuery{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(report.Modes) != 2 {
+		t.Fatalf("expected 2 modes, got %d", len(report.Modes))
+	}
+	if report.Modes[0].MRR != 1 || report.Modes[1].MRR != 0 {
+		t.Errorf("unexpected mode metrics: %+v, %+v", report.Modes[0].Metrics, report.Modes[1].Metrics)
+	}
+	if math.Abs(report.Total.Recall-0.5) > 1e-9 {
+		t.Errorf("expected total recall 0.5, got %v", report.Total.Recall)
+	}
+}
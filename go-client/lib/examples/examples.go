@@ -0,0 +1,116 @@
+// Package examples keeps few-shot examples of chats: pairs of a user input
+// and the expected answer, searched by similarity to the current user turn.
+package examples
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"go-client/lib/cocktail"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EmbedFunc computes vectors of example inputs when the store doesn't vectorize them itself.
+type EmbedFunc = cocktail.EmbedFunc
+
+type Example struct {
+	Input  string `yaml:"input" json:"input"`
+	Output string `yaml:"output" json:"output"`
+}
+
+// ScoredExample is an example with its vector distance to the searched text.
+type ScoredExample struct {
+	Example
+	Distance float32 `json:"distance"`
+}
+
+// ExampleStore holds examples of one chat.
+type ExampleStore interface {
+	Add(ctx context.Context, ex Example) error
+	List(ctx context.Context) ([]Example, error)
+	// Similar returns at most k examples with input closest to text,
+	// maxDistance > 0 drops examples further than that.
+	Similar(ctx context.Context, text string, k int, maxDistance float32) ([]ScoredExample, error)
+}
+
+// ClassName is the Weaviate collection of a chat's examples, e.g. ExampleWaiter.
+func ClassName(chat string) string {
+	var builder strings.Builder
+	builder.WriteString("Example")
+	upper := true
+	for _, r := range chat {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// Format writes examples the way they are put into the [EXAMPLES] prompt section.
+func Format(examples []Example) string {
+	var builder strings.Builder
+	for i, ex := range examples {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		builder.WriteString(fmt.Sprintf("User: %s\nAssistant: %s\n", strings.TrimSpace(ex.Input), strings.TrimSpace(ex.Output)))
+	}
+	return builder.String()
+}
+
+// LoadFile reads examples from a YAML list or a JSONL file (.jsonl).
+func LoadFile(path string) ([]Example, error) {
+	var examples []Example
+
+	if filepath.Ext(path) == ".jsonl" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var ex Example
+			if err := json.Unmarshal(scanner.Bytes(), &ex); err != nil {
+				return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+			}
+			examples = append(examples, ex)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &examples); err != nil {
+			return nil, fmt.Errorf("YAML parsing error in %s: %w", path, err)
+		}
+	}
+
+	for i, ex := range examples {
+		if strings.TrimSpace(ex.Input) == "" || strings.TrimSpace(ex.Output) == "" {
+			return nil, fmt.Errorf("%s: example #%d needs input and output", path, i+1)
+		}
+	}
+	return examples, nil
+}
@@ -0,0 +1,83 @@
+package examples
+
+import (
+	"context"
+	"go-client/lib/fakellm"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClassName(t *testing.T) {
+	tests := map[string]string{
+		"waiter":      "ExampleWaiter",
+		"head-barman": "ExampleHeadBarman",
+		"bar_2":       "ExampleBar2",
+	}
+	for chat, expected := range tests {
+		if got := ClassName(chat); got != expected {
+			t.Errorf("%s: expected %s, got %s", chat, expected, got)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "examples.yaml")
+	jsonlFile := filepath.Join(dir, "examples.jsonl")
+	os.WriteFile(yamlFile, []byte("- input: hi\n  output: hello\n"), 0o644)
+	os.WriteFile(jsonlFile, []byte(`{"input": "hi", "output": "hello"}`+"\n\n"), 0o644)
+
+	for _, file := range []string{yamlFile, jsonlFile} {
+		items, err := LoadFile(file)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got: %v", file, err)
+		}
+		if len(items) != 1 || items[0] != (Example{Input: "hi", Output: "hello"}) {
+			t.Errorf("%s: unexpected examples: %+v", file, items)
+		}
+	}
+
+	os.WriteFile(yamlFile, []byte("- input: hi\n"), 0o644)
+	if _, err := LoadFile(yamlFile); err == nil {
+		t.Error("expected error for example without output, got nil")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "waiter.jsonl")
+	embed := func(ctx context.Context, text string) ([]float32, error) {
+		return fakellm.Embed(text), nil
+	}
+	ctx := context.Background()
+
+	s := NewFileStore(path, embed)
+	for _, ex := range []Example{
+		{Input: "something with mezcal", Output: "Mezcal Negroni"},
+		{Input: "a refreshing drink for summer", Output: "Mojito"},
+		{Input: "no gin please", Output: "Daiquiri"},
+	} {
+		if err := s.Add(ctx, ex); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+
+	// a new store reads examples added before
+	s = NewFileStore(path, embed)
+	list, err := s.List(ctx)
+	if err != nil || len(list) != 3 {
+		t.Fatalf("expected 3 examples, got %d, %v", len(list), err)
+	}
+
+	similar, err := s.Similar(ctx, "a drink with mezcal", 1, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(similar) != 1 || similar[0].Output != "Mezcal Negroni" {
+		t.Errorf("unexpected similar examples: %+v", similar)
+	}
+
+	if similar, _ := s.Similar(ctx, "whisky", 3, 0.01); len(similar) != 0 {
+		t.Errorf("expected no examples within distance, got %+v", similar)
+	}
+}
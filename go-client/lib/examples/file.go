@@ -0,0 +1,140 @@
+package examples
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// fileStore keeps examples in a JSONL file and their vectors in memory,
+// for the lightweight mode without Weaviate.
+type fileStore struct {
+	path  string
+	embed EmbedFunc
+
+	mu      sync.Mutex
+	loaded  bool
+	items   []Example
+	vectors [][]float32
+}
+
+// NewFileStore opens examples kept in the JSONL file at path, vectors come from embed.
+func NewFileStore(path string, embed EmbedFunc) *fileStore {
+	return &fileStore{path: path, embed: embed}
+}
+
+func (s *fileStore) load() error {
+	if s.loaded {
+		return nil
+	}
+	items, err := LoadFile(s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	s.items = items
+	s.vectors = make([][]float32, len(items))
+	s.loaded = true
+	return nil
+}
+
+func (s *fileStore) Add(ctx context.Context, ex Example) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(ex)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	s.items = append(s.items, ex)
+	s.vectors = append(s.vectors, nil)
+	return nil
+}
+
+func (s *fileStore) List(ctx context.Context) ([]Example, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return append([]Example{}, s.items...), nil
+}
+
+func (s *fileStore) Similar(ctx context.Context, text string, k int, maxDistance float32) ([]ScoredExample, error) {
+	if s.embed == nil {
+		return nil, fmt.Errorf("example search needs an embedding model")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	vector, err := s.embed(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("query embedding: %w", err)
+	}
+
+	results := []ScoredExample{}
+	for i, ex := range s.items {
+		// vectors are computed on first search, adding examples stays cheap
+		if s.vectors[i] == nil {
+			if s.vectors[i], err = s.embed(ctx, ex.Input); err != nil {
+				return nil, fmt.Errorf("embedding of example %q: %w", ex.Input, err)
+			}
+		}
+		distance := 1 - cosineSimilarity(vector, s.vectors[i])
+		if maxDistance > 0 && distance > maxDistance {
+			continue
+		}
+		results = append(results, ScoredExample{Example: ex, Distance: distance})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Distance < results[j].Distance
+	})
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+func cosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
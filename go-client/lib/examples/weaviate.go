@@ -0,0 +1,205 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"go-client/lib/applog"
+	"go-client/lib/cocktail"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// listLimit is the Weaviate default maximum of query results
+const listLimit = 10000
+
+type weaviateStore struct {
+	client     *weaviate.Client
+	className  string
+	vectorizer string
+	embed      EmbedFunc
+}
+
+// NewWeaviateStore keeps examples of a chat in its own Weaviate collection, see ClassName,
+// vectorized by the Weaviate module vectorizer (default cocktail.VectorizerName).
+// With cocktail.VectorizerNone vectors are computed by embed.
+func NewWeaviateStore(client *weaviate.Client, chat string, vectorizer string, embed EmbedFunc) *weaviateStore {
+	if vectorizer == "" {
+		vectorizer = cocktail.VectorizerName
+	}
+	return &weaviateStore{
+		client:     client,
+		className:  ClassName(chat),
+		vectorizer: vectorizer,
+		embed:      embed,
+	}
+}
+
+// newExampleClass vectorizes only the input, examples are searched by user message.
+func newExampleClass(className string, vectorizer string) models.Class {
+	class := models.Class{
+		Class:       className,
+		Description: "Few-shot example of a chat",
+		Vectorizer:  vectorizer,
+		Properties: []*models.Property{
+			{
+				Name:     "input",
+				DataType: []string{"text"},
+			},
+			{
+				Name:     "output",
+				DataType: []string{"text"},
+			},
+		},
+	}
+
+	if vectorizer != cocktail.VectorizerNone {
+		class.Properties[1].ModuleConfig = map[string]interface{}{
+			vectorizer: map[string]interface{}{
+				"skip": true, // exclude from embedding
+			},
+		}
+		class.ModuleConfig = map[string]interface{}{
+			vectorizer: map[string]interface{}{
+				"vectorizeClassName":    false,
+				"vectorizePropertyName": false,
+			},
+		}
+	}
+
+	return class
+}
+
+// ensureClass creates the chat's collection on first use.
+func (s *weaviateStore) ensureClass(ctx context.Context) error {
+	exists, err := s.client.Schema().ClassExistenceChecker().WithClassName(s.className).Do(ctx)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	class := newExampleClass(s.className, s.vectorizer)
+	if err := s.client.Schema().ClassCreator().WithClass(&class).Do(ctx); err != nil {
+		return err
+	}
+	applog.Component("examples").InfoContext(ctx, "class created", "class", s.className)
+	return nil
+}
+
+func (s *weaviateStore) Add(ctx context.Context, ex Example) error {
+	if err := s.ensureClass(ctx); err != nil {
+		return err
+	}
+
+	creator := s.client.Data().Creator().
+		WithClassName(s.className).
+		WithProperties(map[string]interface{}{
+			"input":  ex.Input,
+			"output": ex.Output,
+		})
+
+	if s.embed != nil {
+		vector, err := s.embed(ctx, ex.Input)
+		if err != nil {
+			return fmt.Errorf("embedding of example %q: %w", ex.Input, err)
+		}
+		creator = creator.WithVector(vector)
+	}
+
+	_, err := creator.Do(ctx)
+	return err
+}
+
+func (s *weaviateStore) List(ctx context.Context) ([]Example, error) {
+	response, err := s.client.GraphQL().Get().
+		WithClassName(s.className).
+		WithFields(graphql.Field{Name: "input"}, graphql.Field{Name: "output"}).
+		WithLimit(listLimit).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scored, err := s.buildResult(response)
+	if err != nil {
+		return nil, err
+	}
+	examples := make([]Example, len(scored))
+	for i, ex := range scored {
+		examples[i] = ex.Example
+	}
+	return examples, nil
+}
+
+func (s *weaviateStore) Similar(ctx context.Context, text string, k int, maxDistance float32) ([]ScoredExample, error) {
+	get := s.client.GraphQL().Get().
+		WithClassName(s.className).
+		WithFields(
+			graphql.Field{Name: "input"},
+			graphql.Field{Name: "output"},
+			graphql.Field{Name: "_additional", Fields: []graphql.Field{{Name: "distance"}}},
+		)
+
+	if s.embed != nil {
+		vector, err := s.embed(ctx, text)
+		if err != nil {
+			return nil, fmt.Errorf("query embedding: %w", err)
+		}
+		nearVector := s.client.GraphQL().NearVectorArgBuilder().WithVector(vector)
+		if maxDistance > 0 {
+			nearVector = nearVector.WithDistance(maxDistance)
+		}
+		get = get.WithNearVector(nearVector)
+	} else {
+		nearText := s.client.GraphQL().NearTextArgBuilder().WithConcepts([]string{text})
+		if maxDistance > 0 {
+			nearText = nearText.WithDistance(maxDistance)
+		}
+		get = get.WithNearText(nearText)
+	}
+	if k > 0 {
+		get = get.WithLimit(k)
+	}
+
+	response, err := get.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.buildResult(response)
+}
+
+func (s *weaviateStore) buildResult(response *models.GraphQLResponse) ([]ScoredExample, error) {
+	if len(response.Errors) > 0 {
+		return nil, fmt.Errorf("GQL error: %s", response.Errors[0].Message)
+	}
+
+	getData, ok := response.Data["Get"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no Get field in GQL response")
+	}
+	items, ok := getData[s.className].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no %s field in GQL response", s.className)
+	}
+
+	examples := make([]ScoredExample, 0, len(items))
+	for _, i := range items {
+		item, ok := i.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected %s item in GQL response", s.className)
+		}
+		ex := ScoredExample{}
+		ex.Input, _ = item["input"].(string)
+		ex.Output, _ = item["output"].(string)
+		if additional, ok := item["_additional"].(map[string]interface{}); ok {
+			if d, ok := additional["distance"].(float64); ok {
+				ex.Distance = float32(d)
+			}
+		}
+		examples = append(examples, ex)
+	}
+	return examples, nil
+}
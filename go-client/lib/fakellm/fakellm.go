@@ -0,0 +1,310 @@
+// Package fakellm is a scriptable in-process server speaking the OpenAI
+// chat-completions API, so code using a model can be tested without Ollama.
+//
+//	llm := fakellm.New()
+//	defer llm.Close()
+//	llm.On(`(?i)weather`).CallTool("get_current_weather", `{"location": "Warsaw"}`).Once()
+//	llm.OnRole(openai.ChatMessageRoleTool, `celsius`).Reply("It is 23.5°C")
+//	t.Setenv("OPENAI_URL", llm.URL())
+package fakellm
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// EmbeddingSize is the length of vectors returned by the embeddings endpoint.
+const EmbeddingSize = 64
+
+// Rule tells what to answer to requests whose last message matches.
+type Rule struct {
+	role      string
+	pattern   *regexp.Regexp
+	content   string
+	toolCalls []openai.ToolCall
+	times     int
+	used      int
+}
+
+// Reply sets the answer content.
+func (r *Rule) Reply(content string) *Rule {
+	r.content = content
+	return r
+}
+
+// CallTool adds a tool call to the answer.
+func (r *Rule) CallTool(name string, arguments string) *Rule {
+	r.toolCalls = append(r.toolCalls, openai.ToolCall{
+		ID:       fmt.Sprintf("call_%s_%d", name, len(r.toolCalls)+1),
+		Type:     openai.ToolTypeFunction,
+		Function: openai.FunctionCall{Name: name, Arguments: arguments},
+	})
+	return r
+}
+
+// Times limits how many requests the rule answers, later ones fall through to next rules.
+func (r *Rule) Times(n int) *Rule {
+	r.times = n
+	return r
+}
+
+// Once is Times(1).
+func (r *Rule) Once() *Rule {
+	return r.Times(1)
+}
+
+func (r *Rule) matches(msg openai.ChatCompletionMessage) bool {
+	if r.times > 0 && r.used >= r.times {
+		return false
+	}
+	if r.role != "" && r.role != msg.Role {
+		return false
+	}
+	return r.pattern.MatchString(msg.Content)
+}
+
+type Server struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	rules    []*Rule
+	requests []openai.ChatCompletionRequest
+}
+
+// New starts a server, it must be closed with Close.
+func New() *Server {
+	s := &Server{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", s.chatCompletions)
+	mux.HandleFunc("POST /v1/embeddings", s.embeddings)
+	s.server = httptest.NewServer(mux)
+
+	return s
+}
+
+// URL is the base URL for an OpenAI client, e.g. OPENAI_URL.
+func (s *Server) URL() string {
+	return s.server.URL + "/v1"
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// On adds a rule for requests whose last message content matches pattern.
+// Rules are checked in the order they were added.
+func (s *Server) On(pattern string) *Rule {
+	return s.OnRole("", pattern)
+}
+
+// OnRole is On limited to a last message with the given role, e.g. tool results.
+func (s *Server) OnRole(role string, pattern string) *Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := &Rule{role: role, pattern: regexp.MustCompile(pattern)}
+	s.rules = append(s.rules, r)
+	return r
+}
+
+// Requests returns chat completion requests received so far.
+func (s *Server) Requests() []openai.ChatCompletionRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]openai.ChatCompletionRequest{}, s.requests...)
+}
+
+func (s *Server) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req openai.ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeError(w, http.StatusBadRequest, "no messages")
+		return
+	}
+
+	rule := s.match(req)
+	if rule == nil {
+		last := req.Messages[len(req.Messages)-1]
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("fakellm: no rule for %s message %q", last.Role, last.Content))
+		return
+	}
+
+	msg := openai.ChatCompletionMessage{
+		Role:      openai.ChatMessageRoleAssistant,
+		Content:   rule.content,
+		ToolCalls: rule.toolCalls,
+	}
+	finishReason := openai.FinishReasonStop
+	if len(msg.ToolCalls) > 0 {
+		finishReason = openai.FinishReasonToolCalls
+	}
+	usage := openai.Usage{
+		PromptTokens:     countTokens(req.Messages),
+		CompletionTokens: len(strings.Fields(msg.Content)),
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+	if req.Stream {
+		writeStream(w, req.Model, msg, finishReason, usage)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+		ID:      "chatcmpl-fake",
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Choices: []openai.ChatCompletionChoice{{Index: 0, Message: msg, FinishReason: finishReason}},
+		Usage:   usage,
+	})
+}
+
+func (s *Server) match(req openai.ChatCompletionRequest) *Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, req)
+	last := req.Messages[len(req.Messages)-1]
+	for _, rule := range s.rules {
+		if rule.matches(last) {
+			rule.used++
+			return rule
+		}
+	}
+	return nil
+}
+
+// writeStream sends the answer as server-sent events: content word by word,
+// tool calls in one chunk, then the finish reason and usage.
+func writeStream(w http.ResponseWriter, model string, msg openai.ChatCompletionMessage, finishReason openai.FinishReason, usage openai.Usage) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+
+	send := func(delta openai.ChatCompletionStreamChoiceDelta, finish openai.FinishReason, usage *openai.Usage) {
+		chunk := openai.ChatCompletionStreamResponse{
+			ID:      "chatcmpl-fake",
+			Object:  "chat.completion.chunk",
+			Created: time.Now().Unix(),
+			Model:   model,
+			Choices: []openai.ChatCompletionStreamChoice{{Index: 0, Delta: delta, FinishReason: finish}},
+			Usage:   usage,
+		}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	send(openai.ChatCompletionStreamChoiceDelta{Role: openai.ChatMessageRoleAssistant}, "", nil)
+	for _, word := range strings.SplitAfter(msg.Content, " ") {
+		if word != "" {
+			send(openai.ChatCompletionStreamChoiceDelta{Content: word}, "", nil)
+		}
+	}
+	if len(msg.ToolCalls) > 0 {
+		toolCalls := make([]openai.ToolCall, len(msg.ToolCalls))
+		for i, tc := range msg.ToolCalls {
+			index := i
+			tc.Index = &index
+			toolCalls[i] = tc
+		}
+		send(openai.ChatCompletionStreamChoiceDelta{ToolCalls: toolCalls}, "", nil)
+	}
+	send(openai.ChatCompletionStreamChoiceDelta{}, finishReason, &usage)
+
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+// embeddings returns deterministic bag-of-words vectors: texts sharing
+// words get similar vectors, so similarity search behaves sensibly.
+func (s *Server) embeddings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model string      `json:"model"`
+		Input interface{} `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+
+	inputs := []string{}
+	switch v := req.Input.(type) {
+	case string:
+		inputs = append(inputs, v)
+	case []interface{}:
+		for _, i := range v {
+			if text, ok := i.(string); ok {
+				inputs = append(inputs, text)
+			}
+		}
+	}
+
+	resp := openai.EmbeddingResponse{Object: "list", Model: openai.EmbeddingModel(req.Model)}
+	for i, text := range inputs {
+		resp.Data = append(resp.Data, openai.Embedding{Object: "embedding", Index: i, Embedding: Embed(text)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Embed is the vector the server returns for a text.
+func Embed(text string) []float32 {
+	vector := make([]float32, EmbeddingSize)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		word = strings.Trim(word, ".,;:!?\"'()")
+		if word == "" {
+			continue
+		}
+		h := fnv.New32a()
+		h.Write([]byte(word))
+		vector[h.Sum32()%EmbeddingSize]++
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v * v)
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vector {
+			vector[i] = float32(float64(vector[i]) / norm)
+		}
+	}
+	return vector
+}
+
+func countTokens(messages []openai.ChatCompletionMessage) int {
+	n := 0
+	for _, m := range messages {
+		n += len(strings.Fields(m.Content))
+	}
+	return n
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{"message": message, "type": "fakellm_error"},
+	})
+}
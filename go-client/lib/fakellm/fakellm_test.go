@@ -0,0 +1,111 @@
+package fakellm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func newClient(llm *Server) *openai.Client {
+	config := openai.DefaultConfig("test")
+	config.BaseURL = llm.URL()
+	return openai.NewClientWithConfig(config)
+}
+
+func TestServer_Rules(t *testing.T) {
+	llm := New()
+	defer llm.Close()
+	llm.On(`(?i)weather`).CallTool("get_current_weather", `{"location": "Warsaw"}`).Once()
+	llm.On(`(?i)weather`).Reply("Ask me later")
+	llm.OnRole(openai.ChatMessageRoleTool, `celsius`).Reply("It is warm")
+
+	client := newClient(llm)
+	ask := func(msgs ...openai.ChatCompletionMessage) openai.ChatCompletionMessage {
+		resp, err := client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{Model: "fake", Messages: msgs})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		return resp.Choices[0].Message
+	}
+
+	user := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: "What's the weather?"}
+	if msg := ask(user); len(msg.ToolCalls) != 1 || msg.ToolCalls[0].Function.Name != "get_current_weather" {
+		t.Errorf("expected tool call, got: %+v", msg)
+	}
+	if msg := ask(user); msg.Content != "Ask me later" {
+		t.Errorf("expected second rule after first was used, got: %+v", msg)
+	}
+	tool := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleTool, Content: `{"temperature_celsius": 23}`}
+	if msg := ask(user, tool); msg.Content != "It is warm" {
+		t.Errorf("expected tool result rule, got: %+v", msg)
+	}
+
+	if _, err := client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
+		Model:    "fake",
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hello"}},
+	}); err == nil || !strings.Contains(err.Error(), "no rule") {
+		t.Errorf("expected no rule error, got: %v", err)
+	}
+
+	if n := len(llm.Requests()); n != 4 {
+		t.Errorf("expected 4 recorded requests, got %d", n)
+	}
+}
+
+func TestServer_Stream(t *testing.T) {
+	llm := New()
+	defer llm.Close()
+	llm.On(`.*`).Reply("Negroni is bitter").CallTool("cocktail_recipe", `{"cocktail_name": "Negroni"}`)
+
+	stream, err := newClient(llm).CreateChatCompletionStream(context.Background(), openai.ChatCompletionRequest{
+		Model:    "fake",
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Negroni"}},
+		Stream:   true,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer stream.Close()
+
+	var content strings.Builder
+	var toolCalls []openai.ToolCall
+	var finish openai.FinishReason
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("stream error: %v", err)
+		}
+		content.WriteString(chunk.Choices[0].Delta.Content)
+		toolCalls = append(toolCalls, chunk.Choices[0].Delta.ToolCalls...)
+		if chunk.Choices[0].FinishReason != "" {
+			finish = chunk.Choices[0].FinishReason
+		}
+	}
+
+	if content.String() != "Negroni is bitter" {
+		t.Errorf("unexpected streamed content: %q", content.String())
+	}
+	if len(toolCalls) != 1 || finish != openai.FinishReasonToolCalls {
+		t.Errorf("unexpected tool calls %+v or finish reason %q", toolCalls, finish)
+	}
+}
+
+func TestServer_Embeddings(t *testing.T) {
+	llm := New()
+	defer llm.Close()
+
+	resp, err := newClient(llm).CreateEmbeddings(context.Background(), openai.EmbeddingRequest{Model: "fake", Input: "gin and tonic"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(resp.Data) != 1 || len(resp.Data[0].Embedding) != EmbeddingSize {
+		t.Fatalf("unexpected embeddings: %+v", resp.Data)
+	}
+}
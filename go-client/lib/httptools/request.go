@@ -1,9 +1,31 @@
 package httptools
 
+import (
+	"encoding/json"
+	"go-client/lib/usage"
+)
+
+const (
+	// TraceIDHeader carries the trace ID of a request to agents, logged on every line.
+	TraceIDHeader = "X-Trace-Id"
+	// SessionIDHeader carries the session ID of the caller, an agent keeps
+	// a session for every caller session.
+	SessionIDHeader = "X-correlationId"
+)
+
+// RequestData is a user message for a chat. User profile fields and
+// Retrieved context are available in its prompt templates as .User and .Retrieved.
 type RequestData struct {
-	Content string `json:"content"`
+	Content   string            `json:"content"`
+	User      map[string]string `json:"user,omitempty"`
+	Retrieved string            `json:"retrieved,omitempty"`
 }
 
+// ResponseData is an answer of a chat. Data holds the validated JSON answer
+// of chats with a response format, Content the answer text. Usage is what
+// answering took, agents calling the chat add it to their own.
 type ResponseData struct {
-	Content string `json:"content"`
+	Content string          `json:"content"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Usage   *usage.Usage    `json:"usage,omitempty"`
 }
@@ -0,0 +1,44 @@
+package metrics
+
+import "time"
+
+// Metrics of agents and chat servers.
+var (
+	ModelRequests        = NewCounter("model_requests_total", "Model requests by model and status (ok, error).", "model", "status")
+	ModelRequestDuration = NewHistogram("model_request_duration_seconds", "Duration of model requests.", nil, "model")
+	ModelTokens          = NewCounter("model_tokens_total", "Tokens used by model and type (prompt, completion).", "model", "type")
+
+	ToolCalls        = NewCounter("tool_calls_total", "Tool calls by function and status (ok, error).", "function", "status")
+	ToolCallDuration = NewHistogram("tool_call_duration_seconds", "Duration of tool calls.", nil, "function")
+	// unknown function names are counted as "unknown", names from the model aren't labels
+	ToolValidationFailures = NewCounter("tool_validation_failures_total", "Tool calls rejected before running, by function.", "function")
+
+	StoreQueryDuration = NewHistogram("store_query_duration_seconds", "Duration of store queries by store (weaviate, memory) and query.", nil, "store", "query")
+
+	ActiveSessions       = NewGauge("sessions_active", "Open chat sessions.", "chat")
+	WebSocketConnections = NewGauge("websocket_connections_active", "Open WebSocket connections of the chat page.")
+
+	HTTPRequests        = NewCounter("http_requests_total", "HTTP requests by method, route and status code.", "method", "route", "code")
+	HTTPRequestDuration = NewHistogram("http_request_duration_seconds", "Duration of HTTP requests.", nil, "method", "route")
+)
+
+// ObserveModel records a model request.
+func ObserveModel(model string, promptTokens int, completionTokens int, duration time.Duration, err error) {
+	ModelRequests.Inc(model, status(err))
+	ModelRequestDuration.Observe(duration.Seconds(), model)
+	ModelTokens.Add(float64(promptTokens), model, "prompt")
+	ModelTokens.Add(float64(completionTokens), model, "completion")
+}
+
+// ObserveToolCall records a tool call.
+func ObserveToolCall(function string, duration time.Duration, err error) {
+	ToolCalls.Inc(function, status(err))
+	ToolCallDuration.Observe(duration.Seconds(), function)
+}
+
+func status(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
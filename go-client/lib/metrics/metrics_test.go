@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestWrite(t *testing.T) {
+	c := NewCounter("test_requests_total", "Test requests.", "path")
+	c.Inc(`/a"b`)
+	c.Add(2, "/c")
+	g := NewGauge("test_open", "Open things.")
+	g.Inc()
+	g.Inc()
+	g.Dec()
+	h := NewHistogram("test_duration_seconds", "Test durations.", []float64{1, 0.1}, "op")
+	h.Observe(0.05, "get")
+	h.Observe(0.5, "get")
+	h.Observe(5, "get")
+
+	var b strings.Builder
+	if err := Write(&b); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"# HELP test_requests_total Test requests.\n# TYPE test_requests_total counter\n",
+		`test_requests_total{path="/a\"b"} 1` + "\n",
+		`test_requests_total{path="/c"} 2` + "\n",
+		"# TYPE test_open gauge\ntest_open 1\n",
+		"# TYPE test_duration_seconds histogram\n" +
+			`test_duration_seconds_bucket{op="get",le="0.1"} 1` + "\n" +
+			`test_duration_seconds_bucket{op="get",le="1"} 2` + "\n" +
+			`test_duration_seconds_bucket{op="get",le="+Inf"} 3` + "\n" +
+			`test_duration_seconds_sum{op="get"} 5.55` + "\n" +
+			`test_duration_seconds_count{op="get"} 3` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	r.Method(http.MethodGet, "/metrics", Handler())
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for _, path := range []string{"/items/1", "/items/2", "/nothing"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/items/{id}",code="418"} 2`,
+		`http_requests_total{method="GET",route="unmatched",code="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/items/{id}"} 2`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected %q in metrics:\n%s", want, body)
+		}
+	}
+}
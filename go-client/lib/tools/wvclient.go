@@ -2,26 +2,28 @@ package tools
 
 import (
 	"context"
-	"log"
+	"fmt"
 
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
 )
 
-func GetWeaviateClient(scheme string, host string) *weaviate.Client {
+// GetWeaviateClient connects to Weaviate and checks it is ready.
+func GetWeaviateClient(ctx context.Context, scheme string, host string) (*weaviate.Client, error) {
 	cfg := weaviate.Config{
 		Scheme: scheme,
 		Host:   host,
 	}
 	client, err := weaviate.NewClient(cfg)
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		return nil, fmt.Errorf("weaviate client: %w", err)
 	}
 
-	_, err = client.Misc().ReadyChecker().Do(context.Background())
+	ready, err := client.Misc().ReadyChecker().Do(ctx)
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		return nil, fmt.Errorf("weaviate at %s://%s is not ready: %w", scheme, host, err)
 	}
-	return client
+	if !ready {
+		return nil, fmt.Errorf("weaviate at %s://%s is not ready", scheme, host)
+	}
+	return client, nil
 }
@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetWeaviateClient_Cancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client, err := GetWeaviateClient(ctx, "http", strings.TrimPrefix(srv.URL, "http://"))
+	if err == nil || client != nil {
+		t.Fatalf("expected error for cancelled context, got client %v", client)
+	}
+	if !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("expected cancellation error, got: %v", err)
+	}
+}
+
+func TestGetWeaviateClient_NotReady(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	if _, err := GetWeaviateClient(context.Background(), "http", strings.TrimPrefix(srv.URL, "http://")); err == nil {
+		t.Fatal("expected error for a Weaviate that is not ready, got nil")
+	}
+}
@@ -0,0 +1,277 @@
+// Package usage accounts tokens, cost and time spent answering: model
+// requests per model, tool calls, store queries and usage reported by
+// agents called as tools. A Recorder collects a turn, Record adds it to
+// process totals per chat.
+package usage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Tokens counts tokens of model requests.
+type Tokens struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+func (t *Tokens) add(other Tokens) {
+	t.PromptTokens += other.PromptTokens
+	t.CompletionTokens += other.CompletionTokens
+	t.TotalTokens += other.TotalTokens
+}
+
+// Calls counts calls of one kind and time spent in them.
+type Calls struct {
+	Count    int           `json:"count"`
+	Duration time.Duration `json:"duration"`
+}
+
+func (c *Calls) add(other Calls) {
+	c.Count += other.Count
+	c.Duration += other.Duration
+}
+
+// Model is usage of one model.
+type Model struct {
+	Tokens
+	Calls Calls   `json:"calls"`
+	Cost  float64 `json:"cost,omitempty"`
+}
+
+// Usage is what answering took. Tokens and Cost are totals of own model
+// requests and of agents.
+type Usage struct {
+	Tokens
+	Cost   float64           `json:"cost,omitempty"`
+	Models map[string]*Model `json:"models,omitempty"`
+	Agents map[string]*Usage `json:"agents,omitempty"` // reported by agents called as tools
+	Tools  Calls             `json:"tools"`
+	Store  Calls             `json:"store"`
+}
+
+// AddModel adds a model request.
+func (u *Usage) AddModel(model string, tokens Tokens, duration time.Duration, cost float64) {
+	if u.Models == nil {
+		u.Models = map[string]*Model{}
+	}
+	m, ok := u.Models[model]
+	if !ok {
+		m = &Model{}
+		u.Models[model] = m
+	}
+	m.Tokens.add(tokens)
+	m.Calls.add(Calls{Count: 1, Duration: duration})
+	m.Cost += cost
+
+	u.Tokens.add(tokens)
+	u.Cost += cost
+}
+
+// AddAgent adds usage reported by an agent.
+func (u *Usage) AddAgent(agent string, other Usage) {
+	if u.Agents == nil {
+		u.Agents = map[string]*Usage{}
+	}
+	a, ok := u.Agents[agent]
+	if !ok {
+		a = &Usage{}
+		u.Agents[agent] = a
+	}
+	a.Add(other)
+
+	u.Tokens.add(other.Tokens)
+	u.Cost += other.Cost
+}
+
+// Add adds all of other.
+func (u *Usage) Add(other Usage) {
+	u.Tokens.add(other.Tokens)
+	u.Cost += other.Cost
+	for name, m := range other.Models {
+		if u.Models == nil {
+			u.Models = map[string]*Model{}
+		}
+		own, ok := u.Models[name]
+		if !ok {
+			own = &Model{}
+			u.Models[name] = own
+		}
+		own.Tokens.add(m.Tokens)
+		own.Calls.add(m.Calls)
+		own.Cost += m.Cost
+	}
+	for name, a := range other.Agents {
+		if u.Agents == nil {
+			u.Agents = map[string]*Usage{}
+		}
+		own, ok := u.Agents[name]
+		if !ok {
+			own = &Usage{}
+			u.Agents[name] = own
+		}
+		own.Add(*a)
+	}
+	u.Tools.add(other.Tools)
+	u.Store.add(other.Store)
+}
+
+// Clone returns a deep copy.
+func (u Usage) Clone() Usage {
+	var c Usage
+	c.Add(u)
+	return c
+}
+
+// Recorder collects usage of concurrent calls, methods of a nil Recorder do nothing.
+type Recorder struct {
+	mu    sync.Mutex
+	usage Usage
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) Model(model string, tokens Tokens, duration time.Duration, cost float64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.usage.AddModel(model, tokens, duration, cost)
+}
+
+func (r *Recorder) Agent(agent string, other Usage) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.usage.AddAgent(agent, other)
+}
+
+func (r *Recorder) Tool(duration time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.usage.Tools.add(Calls{Count: 1, Duration: duration})
+}
+
+func (r *Recorder) Store(duration time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.usage.Store.add(Calls{Count: 1, Duration: duration})
+}
+
+// Usage returns a copy of usage collected so far.
+func (r *Recorder) Usage() Usage {
+	if r == nil {
+		return Usage{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.usage.Clone()
+}
+
+// TotalTokens returns tokens used so far.
+func (r *Recorder) TotalTokens() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.usage.TotalTokens
+}
+
+type ctxKey struct{}
+
+// NewContext returns a context whose calls are recorded by r.
+func NewContext(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, ctxKey{}, r)
+}
+
+// FromContext returns the Recorder of ctx, nil if there is none.
+func FromContext(ctx context.Context) *Recorder {
+	r, _ := ctx.Value(ctxKey{}).(*Recorder)
+	return r
+}
+
+// process totals per chat
+var (
+	totalsMu sync.Mutex
+	totals   = map[string]*Usage{}
+	onRecord []func(chat string, u Usage)
+)
+
+// Record adds usage of a chat turn to process totals.
+func Record(chat string, u Usage) {
+	totalsMu.Lock()
+	t, ok := totals[chat]
+	if !ok {
+		t = &Usage{}
+		totals[chat] = t
+	}
+	t.Add(u)
+	hooks := onRecord
+	totalsMu.Unlock()
+
+	for _, fn := range hooks {
+		fn(chat, u)
+	}
+}
+
+// OnRecord adds a function called with every recorded turn, e.g. to export metrics.
+func OnRecord(fn func(chat string, u Usage)) {
+	totalsMu.Lock()
+	defer totalsMu.Unlock()
+	onRecord = append(onRecord, fn)
+}
+
+// ByChat returns process totals per chat.
+func ByChat() map[string]Usage {
+	totalsMu.Lock()
+	defer totalsMu.Unlock()
+	byChat := make(map[string]Usage, len(totals))
+	for chat, u := range totals {
+		byChat[chat] = u.Clone()
+	}
+	return byChat
+}
+
+// ByModel returns process totals of own model requests per model, over all chats.
+func ByModel() map[string]Model {
+	totalsMu.Lock()
+	defer totalsMu.Unlock()
+	byModel := map[string]Model{}
+	for _, u := range totals {
+		for name, m := range u.Models {
+			total := byModel[name]
+			total.Tokens.add(m.Tokens)
+			total.Calls.add(m.Calls)
+			total.Cost += m.Cost
+			byModel[name] = total
+		}
+	}
+	return byModel
+}
+
+// Chats returns names of chats with recorded usage, sorted.
+func Chats() []string {
+	totalsMu.Lock()
+	defer totalsMu.Unlock()
+	chats := make([]string, 0, len(totals))
+	for chat := range totals {
+		chats = append(chats, chat)
+	}
+	sort.Strings(chats)
+	return chats
+}
@@ -0,0 +1,83 @@
+package usage
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecorder_Totals(t *testing.T) {
+	r := NewRecorder()
+	ctx := NewContext(context.Background(), r)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			FromContext(ctx).Model("small", Tokens{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, time.Millisecond, 0.5)
+			FromContext(ctx).Tool(time.Millisecond)
+		}()
+	}
+	wg.Wait()
+	r.Store(time.Second)
+	r.Agent("waiter", Usage{
+		Tokens: Tokens{TotalTokens: 100},
+		Cost:   1,
+		Models: map[string]*Model{"big": {Tokens: Tokens{TotalTokens: 100}, Calls: Calls{Count: 1}, Cost: 1}},
+	})
+
+	u := r.Usage()
+	if u.TotalTokens != 250 || u.Cost != 6 {
+		t.Errorf("expected 250 tokens for 6, got %d for %g", u.TotalTokens, u.Cost)
+	}
+	if m := u.Models["small"]; m.Calls.Count != 10 || m.PromptTokens != 100 || m.Calls.Duration != 10*time.Millisecond {
+		t.Errorf("unexpected model usage: %+v", m)
+	}
+	if u.Models["big"] != nil || u.Agents["waiter"].Models["big"].TotalTokens != 100 {
+		t.Errorf("expected agent models kept apart, got %+v", u)
+	}
+	if u.Tools.Count != 10 || u.Store.Count != 1 {
+		t.Errorf("unexpected calls: tools %+v, store %+v", u.Tools, u.Store)
+	}
+
+	// a copy isn't changed by later calls
+	r.Model("small", Tokens{TotalTokens: 1}, 0, 0)
+	if u.Models["small"].TotalTokens != 150 {
+		t.Errorf("expected copy unchanged, got %+v", u.Models["small"])
+	}
+}
+
+func TestRecorder_Nil(t *testing.T) {
+	r := FromContext(context.Background())
+	r.Model("small", Tokens{TotalTokens: 1}, 0, 0)
+	r.Tool(time.Second)
+	if r.TotalTokens() != 0 || r.Usage().Tools.Count != 0 {
+		t.Error("expected nil recorder to record nothing")
+	}
+}
+
+func TestRecord_ByChat(t *testing.T) {
+	var hooked int
+	OnRecord(func(chat string, u Usage) {
+		if chat == "test-bartender" {
+			hooked += u.TotalTokens
+		}
+	})
+
+	turn := Usage{}
+	turn.AddModel("small", Tokens{TotalTokens: 20}, time.Second, 0)
+	Record("test-bartender", turn)
+	Record("test-bartender", turn)
+
+	if u := ByChat()["test-bartender"]; u.TotalTokens != 40 || u.Models["small"].Calls.Count != 2 {
+		t.Errorf("unexpected chat totals: %+v", u)
+	}
+	if m := ByModel()["small"]; m.TotalTokens < 40 {
+		t.Errorf("unexpected model totals: %+v", m)
+	}
+	if hooked != 40 {
+		t.Errorf("expected hook called with 40 tokens, got %d", hooked)
+	}
+}
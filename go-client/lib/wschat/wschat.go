@@ -1,29 +1,51 @@
 package wschat
 
 import (
+	"context"
 	"fmt"
+	"go-client/lib/applog"
+	"go-client/lib/metrics"
 	"log"
+	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+// Session answers messages of one WebSocket connection.
+type Session interface {
+	Ask(ctx context.Context, inputMsg string) (string, error)
+	Close()
+}
+
 type wschat struct {
-	port         int
-	staticDir    string
-	interlocutor func(inputMsg string) (string, error)
+	port       int
+	staticDir  string
+	newSession func(sessionId string) (Session, error)
 }
 
-func New(port int, interlocutor func(inputMsg string) (string, error)) *wschat {
+// New serves the chat page on port, every connection talks to its own
+// session made by newSession. A connection whose session can't be made is
+// closed with an internal error close frame.
+func New(port int, newSession func(sessionId string) (Session, error)) *wschat {
 	ch := &wschat{
-		port:         port,
-		staticDir:    "./static",
-		interlocutor: interlocutor,
+		port:       port,
+		staticDir:  "./static",
+		newSession: newSession,
 	}
 	return ch
 }
 
 func (ch *wschat) Serve() {
+	logger().Info("server starting", "port", ch.port)
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", ch.port), ch.Handler()))
+}
+
+// Handler serves the chat page, the /ws WebSocket endpoint and /metrics.
+func (ch *wschat) Handler() http.Handler {
+	mux := http.NewServeMux()
 
 	upgrader := websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
@@ -31,37 +53,81 @@ func (ch *wschat) Serve() {
 		},
 	}
 
-	http.Handle("/", http.FileServer(http.Dir(ch.staticDir)))
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/", http.FileServer(http.Dir(ch.staticDir)))
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
-			log.Println("Upgrade error:", err)
+			logger().Error("WebSocket upgrade failed", "err", err)
 			return
 		}
 		defer conn.Close()
+		metrics.WebSocketConnections.Inc()
+		defer metrics.WebSocketConnections.Dec()
+
+		// cancelled when the client disconnects, so a pending answer is abandoned;
+		// log lines of the connection carry its session ID
+		sessionId := uuid.NewString()
+		ctx, cancel := context.WithCancel(applog.WithAttrs(r.Context(), applog.SessionKey, sessionId))
+		defer cancel()
+
+		session, err := ch.newSession(sessionId)
+		if err != nil {
+			logger().ErrorContext(ctx, "session start failed", "err", err)
+			closeMsg := websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "session start failed")
+			conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+			return
+		}
+		defer session.Close()
+
+		logger().InfoContext(ctx, "WebSocket connection opened")
+		receivedCh := make(chan string)
+		go func() {
+			defer cancel()
+			for {
+				_, receivedMsg, err := conn.ReadMessage()
+				if err != nil {
+					logger().InfoContext(ctx, "WebSocket connection closed", "reason", err)
+					return
+				}
+				select {
+				case receivedCh <- string(receivedMsg):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
 
-		log.Println("New WebSocket connection")
 		for {
-			_, receivedMsg, err := conn.ReadMessage()
-			if err != nil {
-				log.Println("Chat read error:", err)
-				break
+			var receivedMsg string
+			select {
+			case receivedMsg = <-receivedCh:
+			case <-ctx.Done():
+				return
 			}
-			log.Printf("Received from chat: %s", receivedMsg)
+			// every message is a turn with its own trace ID
+			msgCtx := applog.WithTraceID(ctx, uuid.NewString())
+			logger().DebugContext(msgCtx, "message received", "content", receivedMsg)
 
-			responseMsg, err := ch.interlocutor(string(receivedMsg))
+			responseMsg, err := session.Ask(msgCtx, receivedMsg)
 			if err != nil {
-				log.Println("Interlocutor error:", err)
+				logger().ErrorContext(msgCtx, "answering failed", "err", err)
+				if ctx.Err() != nil {
+					return
+				}
 			}
 
 			if err := conn.WriteMessage(websocket.TextMessage, []byte(responseMsg)); err != nil {
-				log.Println("Chat write error:", err)
-				break
+				logger().ErrorContext(msgCtx, "WebSocket write failed", "err", err)
+				return
 			}
-			log.Printf("Response to chat: %s", responseMsg)
+			logger().DebugContext(msgCtx, "answer sent", "content", responseMsg)
 		}
 	})
 
-	log.Printf("Serwer starting on :%d\n", ch.port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", ch.port), nil))
+	return mux
+}
+
+func logger() *slog.Logger {
+	return applog.Component("wschat")
 }
@@ -0,0 +1,117 @@
+package wschat
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-client/lib/applog"
+
+	"github.com/gorilla/websocket"
+)
+
+// echoSession answers with the message, keeping trace IDs of the messages.
+type echoSession struct {
+	id       string
+	closed   chan struct{}
+	traceIDs []string
+}
+
+func (s *echoSession) Ask(ctx context.Context, inputMsg string) (string, error) {
+	s.traceIDs = append(s.traceIDs, applog.TraceID(ctx))
+	return "echo: " + inputMsg, nil
+}
+
+func (s *echoSession) Close() { close(s.closed) }
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	return conn
+}
+
+func TestHandler_WebSocket(t *testing.T) {
+	var mu sync.Mutex
+	var sessions []*echoSession
+	ch := New(0, func(sessionId string) (Session, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		s := &echoSession{id: sessionId, closed: make(chan struct{})}
+		sessions = append(sessions, s)
+		return s, nil
+	})
+	srv := httptest.NewServer(ch.Handler())
+	defer srv.Close()
+
+	conn := dial(t, srv.URL)
+	defer conn.Close()
+
+	for _, msg := range []string{"hello", "again"} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		_, answer, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if string(answer) != "echo: "+msg {
+			t.Errorf("unexpected answer: %s", answer)
+		}
+	}
+
+	// another connection gets its own session, closed on disconnect
+	other := dial(t, srv.URL)
+	other.WriteMessage(websocket.TextMessage, []byte("hi"))
+	other.ReadMessage()
+	other.Close()
+	mu.Lock()
+	if len(sessions) != 2 || sessions[0].id == sessions[1].id {
+		t.Fatalf("expected a session per connection, got %+v", sessions)
+	}
+	closed := sessions[1].closed
+	traceIDs := sessions[0].traceIDs
+	mu.Unlock()
+	if len(traceIDs) != 2 || traceIDs[0] == "" || traceIDs[0] == traceIDs[1] {
+		t.Errorf("expected a trace ID per message, got %q", traceIDs)
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Error("expected session closed after disconnect")
+	}
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "websocket_connections_active 1\n") {
+		t.Errorf("expected 1 open connection in metrics:\n%s", body)
+	}
+}
+
+func TestHandler_SessionStartFails(t *testing.T) {
+	ch := New(0, func(sessionId string) (Session, error) {
+		return nil, errors.New("prompt file missing")
+	})
+	srv := httptest.NewServer(ch.Handler())
+	defer srv.Close()
+
+	conn := dial(t, srv.URL)
+	defer conn.Close()
+
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseInternalServerErr) {
+		t.Errorf("expected internal error close frame, got: %v", err)
+	}
+}